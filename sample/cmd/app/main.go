@@ -57,23 +57,28 @@ func main() {
 	cobweb, err := cobweb.New(
 		&cobweb.Config{
 			Masters: &cluster.Config{
-				Username:     username,
-				Password:     password,
-				Addresses:    mastersAddresses,
-				MaxThreshold: mastersMaxThreshold,
+				Username:      username,
+				Password:      password,
+				Addresses:     mastersAddresses,
+				MaxThreshold:  mastersMaxThreshold,
+				ExitThreshold: cfg.Masters.ExitThreshold,
+				MinDwell:      cfg.Masters.MinDwell,
 			},
 			Replicas: &cluster.Config{
-				Username:     username,
-				Password:     password,
-				Addresses:    replicasAddresses,
-				MaxThreshold: replicasMaxThreshold,
+				Username:      username,
+				Password:      password,
+				Addresses:     replicasAddresses,
+				MaxThreshold:  replicasMaxThreshold,
+				ExitThreshold: cfg.Replicas.ExitThreshold,
+				MinDwell:      cfg.Replicas.MinDwell,
 			},
-			Monitor: &monitor,
+			Monitor: monitor,
 		},
 	)
 	if err != nil {
 		log.Fatalf("failed to create cobweb: %v", err)
 	}
+	defer cobweb.Close()
 
 	// Создаём Redis-клиент (standalone, все адреса)
 	redisClient, err := redis.New(&redis.Config{
@@ -88,7 +93,7 @@ func main() {
 
 	// Создаём сервис
 	svc, err := service.New(service.Config{
-		Cobweb: &cobweb,
+		Cobweb: cobweb,
 		Redis:  &redisClient,
 	})
 	if err != nil {
@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -18,8 +19,10 @@ type RedisConfig struct {
 }
 
 type NodeGroup struct {
-	Addresses    []string `yaml:"addresses"`
-	MaxThreshold float64  `yaml:"maxThreshold"`
+	Addresses     []string      `yaml:"addresses"`
+	MaxThreshold  float64       `yaml:"maxThreshold"`
+	ExitThreshold float64       `yaml:"exitThreshold"`
+	MinDwell      time.Duration `yaml:"minDwell"`
 }
 
 func Load(path string) (*RedisConfig, error) {
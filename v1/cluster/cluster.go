@@ -1,16 +1,184 @@
 package cluster
 
-import "github.com/redis/rueidis"
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kuroko-shirai/axolotl/v1/internal/node"
+)
 
 type (
+	// Cluster группа нод, каждая из которых обслуживается собственным клиентом.
 	Cluster interface {
-		Client() rueidis.Client
+		Nodes() []node.Node            // Ноды группы в порядке конфигурации.
+		Node(string) (node.Node, bool) // Нода группы по адресу.
+		Addresses() []string           // Адреса нод группы в порядке конфигурации.
+		Sync([]string) error           // Приводит состав группы к указанным адресам.
+		Close()                        // Закрывает клиенты всех нод группы.
+
+		// Prepare открывает клиенты к новым адресам, не меняя состав
+		// группы. commit заменяет состав так же, как Sync, abort закрывает
+		// открытые клиенты; вызывать нужно ровно одну из них.
+		Prepare([]string) (commit, abort func(), err error)
 	}
 
 	Config struct {
 		Addresses    []string
 		Username     string
 		Password     string
-		MaxThreshold float64
+		MaxThreshold float64 // Порог, выше которого группа считается перегруженной.
+		// ExitThreshold порог, ниже которого перегруженная группа снова
+		// считается свободной. Ноль или значение выше MaxThreshold отключают
+		// гистерезис.
+		ExitThreshold float64
+		MinDwell      time.Duration // Минимальное время чтения с группы после переключения на неё.
+	}
+
+	// Topology раскладка нод по ролям.
+	Topology struct {
+		Masters  []string
+		Replicas []string
+	}
+
+	// group изменяемый набор нод, общий для копий Masters и Replicas.
+	group struct {
+		syncMu    sync.Mutex // Сериализует Sync.
+		mu        sync.RWMutex
+		username  string
+		password  string
+		addresses []string
+		nodes     []node.Node
+		index     map[string]node.Node
 	}
 )
+
+func newGroup(config *Config) (*group, error) {
+	it := &group{
+		username: config.Username,
+		password: config.Password,
+		index:    make(map[string]node.Node),
+	}
+
+	if err := it.Sync(config.Addresses); err != nil {
+		return nil, err
+	}
+
+	return it, nil
+}
+
+func (it *group) Nodes() []node.Node {
+	it.mu.RLock()
+	defer it.mu.RUnlock()
+	return it.nodes
+}
+
+func (it *group) Node(address string) (node.Node, bool) {
+	it.mu.RLock()
+	defer it.mu.RUnlock()
+	n, ok := it.index[address]
+	return n, ok
+}
+
+func (it *group) Addresses() []string {
+	it.mu.RLock()
+	defer it.mu.RUnlock()
+	return it.addresses
+}
+
+// Sync открывает клиенты к новым адресам и выводит исключённые: клиент
+// исключённой ноды закрывается, когда завершатся все начатые на нём
+// обращения (node.Hold). Новые обращения к ней уже не начинаются.
+// Ноды, которые остаются в группе, сохраняют свои клиенты. При ошибке
+// подключения состав группы не меняется.
+func (it *group) Sync(addresses []string) error {
+	commit, _, err := it.Prepare(addresses)
+	if err != nil {
+		return err
+	}
+
+	commit()
+	return nil
+}
+
+// Prepare открывает клиенты к новым адресам и возвращает commit, который
+// заменяет состав группы, и abort, который закрывает открытые клиенты. До
+// вызова commit или abort другие изменения состава группы ждут. При ошибке
+// подключения открытые клиенты закрываются, а состав группы не меняется.
+func (it *group) Prepare(addresses []string) (commit, abort func(), err error) {
+	it.syncMu.Lock()
+
+	it.mu.RLock()
+	current := it.index
+	it.mu.RUnlock()
+
+	var (
+		nodes   = make([]node.Node, 0, len(addresses))
+		index   = make(map[string]node.Node, len(addresses))
+		created []node.Node
+	)
+	for _, address := range addresses {
+		if _, ok := index[address]; ok {
+			continue
+		}
+
+		n, ok := current[address]
+		if !ok {
+			var err error
+			n, err = node.New(&node.Config{
+				Username: it.username,
+				Password: it.password,
+				Address:  address,
+			})
+			if err != nil {
+				for _, c := range created {
+					c.Close()
+				}
+				it.syncMu.Unlock()
+				return nil, nil, fmt.Errorf("failed to connect to %s: %w", address, err)
+			}
+			created = append(created, n)
+		}
+
+		nodes = append(nodes, n)
+		index[address] = n
+	}
+
+	ordered := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		ordered = append(ordered, n.Address())
+	}
+
+	commit = func() {
+		defer it.syncMu.Unlock()
+
+		it.mu.Lock()
+		previous := it.index
+		it.addresses, it.nodes, it.index = ordered, nodes, index
+		it.mu.Unlock()
+
+		for address, n := range previous {
+			if _, ok := index[address]; !ok {
+				n.Retire()
+			}
+		}
+	}
+	abort = func() {
+		defer it.syncMu.Unlock()
+
+		for _, c := range created {
+			c.Close()
+		}
+	}
+
+	return commit, abort, nil
+}
+
+func (it *group) Close() {
+	it.mu.RLock()
+	defer it.mu.RUnlock()
+
+	for _, n := range it.nodes {
+		n.Close()
+	}
+}
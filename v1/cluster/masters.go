@@ -1,37 +1,20 @@
 package cluster
 
-import (
-	"errors"
-
-	"github.com/redis/rueidis"
-)
-
 type (
 	Masters struct {
-		client rueidis.Client
+		*group
 	}
 )
 
+// NewMasters создаёт группу master-нод. Пустой список адресов допустим:
+// группа остаётся пустой, пока её не заполнит Sync.
 func NewMasters(config *Config) (Masters, error) {
-	if len(config.Addresses) == 0 {
-		return Masters{}, errors.New("invalid masters-cluster: need at least one master")
-	}
-
-	client, err := rueidis.NewClient(rueidis.ClientOption{
-		Username:    config.Username,
-		Password:    config.Password,
-		InitAddress: config.Addresses,
-		ReplicaOnly: false,
-	})
+	group, err := newGroup(config)
 	if err != nil {
 		return Masters{}, err
 	}
 
 	return Masters{
-		client: client,
+		group: group,
 	}, nil
 }
-
-func (it Masters) Client() rueidis.Client {
-	return it.client
-}
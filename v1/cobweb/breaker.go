@@ -0,0 +1,238 @@
+package cobweb
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kuroko-shirai/axolotl/v1/monitor"
+	"github.com/redis/rueidis"
+)
+
+const (
+	defaultCooldown = 5 * time.Second
+	defaultProbes   = 1
+)
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+type (
+	// HealthMonitor монитор, который отслеживает здоровье нод. Если Monitor
+	// его реализует, cobweb не читает с нод в состоянии monitor.Down и
+	// сообщает монитору о результате каждой команды.
+	HealthMonitor interface {
+		Health() map[string]monitor.Health
+		Report(address string, err error)
+	}
+
+	// Breaker настройки размыкателя цепи для чтения с ноды. После Failures
+	// подряд идущих ошибок нода исключается из чтения на Cooldown, затем на
+	// неё пропускается не больше Probes пробных запросов: успех замыкает
+	// цепь, ошибка снова размыкает. Нулевой Failures отключает размыкатель.
+	Breaker struct {
+		Failures int
+		Cooldown time.Duration // По умолчанию 5s.
+		Probes   int           // По умолчанию 1.
+	}
+
+	breakerState uint8
+
+	// breaker состояние размыкателя одной ноды.
+	breaker struct {
+		mu       sync.Mutex
+		state    breakerState
+		failures int
+		openedAt time.Time
+		probes   int
+	}
+)
+
+// validate проверяет настройки размыкателя.
+func (it Breaker) validate() error {
+	if it.Failures < 0 || it.Cooldown < 0 || it.Probes < 0 {
+		return errors.New("invalid breaker: must not be negative")
+	}
+	return nil
+}
+
+func (it Breaker) cooldown() time.Duration {
+	if it.Cooldown == 0 {
+		return defaultCooldown
+	}
+	return it.Cooldown
+}
+
+func (it Breaker) probes() int {
+	if it.Probes == 0 {
+		return defaultProbes
+	}
+	return it.Probes
+}
+
+// available сообщает, можно ли сейчас направить чтение на ноду.
+func (it *breaker) available(config Breaker, now time.Time) bool {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	switch it.state {
+	case breakerOpen:
+		return now.Sub(it.openedAt) >= config.cooldown()
+	case breakerHalfOpen:
+		return it.probes < config.probes()
+	default:
+		return true
+	}
+}
+
+// acquire занимает право на чтение с ноды; в полуоткрытом состоянии —
+// одно из пробных.
+func (it *breaker) acquire(config Breaker, now time.Time) bool {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	switch it.state {
+	case breakerOpen:
+		if now.Sub(it.openedAt) < config.cooldown() {
+			return false
+		}
+		it.state, it.probes = breakerHalfOpen, 1
+		return true
+	case breakerHalfOpen:
+		if it.probes >= config.probes() {
+			return false
+		}
+		it.probes++
+		return true
+	default:
+		return true
+	}
+}
+
+// done учитывает результат чтения, для которого было вызвано acquire.
+func (it *breaker) done(config Breaker, failed bool, now time.Time) {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	switch it.state {
+	case breakerClosed:
+		if !failed {
+			it.failures = 0
+			return
+		}
+		it.failures++
+		if it.failures >= config.Failures {
+			it.state, it.openedAt = breakerOpen, now
+		}
+	case breakerHalfOpen:
+		it.probes--
+		if failed {
+			it.state, it.openedAt = breakerOpen, now
+			return
+		}
+		it.state, it.failures = breakerClosed, 0
+	}
+}
+
+// release возвращает право на чтение без учёта результата.
+func (it *breaker) release() {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	if it.state == breakerHalfOpen {
+		it.probes--
+	}
+}
+
+// breaker возвращает размыкатель ноды.
+func (it *Cobweb) breaker(address string) *breaker {
+	if b, ok := it.breakers.Load(address); ok {
+		return b.(*breaker)
+	}
+	b, _ := it.breakers.LoadOrStore(address, &breaker{})
+	return b.(*breaker)
+}
+
+// available сообщает, пропускает ли размыкатель чтение с ноды.
+func (it *Cobweb) available(settings *settings, address string) bool {
+	if settings.breaker.Failures == 0 {
+		return true
+	}
+	return it.breaker(address).available(settings.breaker, time.Now())
+}
+
+// acquire занимает право на чтение с ноды.
+func (it *Cobweb) acquire(settings *settings, address string) bool {
+	if settings.breaker.Failures == 0 {
+		return true
+	}
+	return it.breaker(address).acquire(settings.breaker, time.Now())
+}
+
+// release возвращает право на чтение с ноды без учёта результата.
+func (it *Cobweb) release(settings *settings, address string) {
+	if settings.breaker.Failures > 0 {
+		it.breaker(address).release()
+	}
+}
+
+// observe сообщает о результате чтения размыкателю и монитору. Ошибка,
+// вызванная истечением срока или отменой ctx вызывающего, ничего не говорит
+// о ноде: она не учитывается ни как отказ, ни как успех.
+func (it *Cobweb) observe(ctx context.Context, settings *settings, address string, results []rueidis.RedisResult) {
+	err := failure(results)
+	if callerDone(ctx, err) {
+		it.release(settings, address)
+		return
+	}
+
+	if hm, ok := settings.monitor.(HealthMonitor); ok {
+		hm.Report(address, err)
+	}
+
+	if settings.breaker.Failures > 0 {
+		it.breaker(address).done(settings.breaker, err != nil, time.Now())
+	}
+}
+
+// failure возвращает первую ошибку, которая говорит о неисправности ноды:
+// сетевую ошибку, истечение времени ожидания или ответы LOADING, BUSY и
+// MASTERDOWN. Прочие ответы Redis, включая nil, означают, что нода работает.
+func failure(results []rueidis.RedisResult) error {
+	for _, result := range results {
+		err := result.Error()
+		if err == nil || rueidis.IsRedisNil(err) || errors.Is(err, context.Canceled) {
+			continue
+		}
+
+		redisErr, ok := rueidis.IsRedisErr(err)
+		if !ok {
+			return err
+		}
+		if unavailable(redisErr) {
+			return err
+		}
+	}
+	return nil
+}
+
+// callerDone сообщает, что err вызвана завершением ctx вызывающего, а не
+// нодой.
+func callerDone(ctx context.Context, err error) bool {
+	return err != nil && ctx.Err() != nil && errors.Is(err, ctx.Err())
+}
+
+// unavailable проверяет, что ответ Redis означает временную недоступность
+// ноды.
+func unavailable(err *rueidis.RedisError) bool {
+	if err.IsLoading() {
+		return true
+	}
+	msg := err.Error()
+	return msg == "BUSY" || strings.HasPrefix(msg, "BUSY ") || strings.HasPrefix(msg, "MASTERDOWN")
+}
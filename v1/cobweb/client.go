@@ -0,0 +1,206 @@
+package cobweb
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/redis/rueidis"
+)
+
+type (
+	// Client реализует rueidis.Client поверх cobweb, чтобы код, который уже
+	// принимает rueidis.Client, работал без изменений. Команды только для
+	// чтения маршрутизируются с учётом загрузки так же, как SingleCmd,
+	// MultiCmd, CacheCmd и MultiCacheCmd; остальные команды, подписки и
+	// выделенные соединения идут на мастер записи.
+	//
+	// Если подходящей ноды нет, команда отправляется на любую ноду групп, а
+	// когда групп не осталось — на ноду, доступную при создании cobweb:
+	// так ошибку возвращает сам rueidis.
+	//
+	// Клиент ноды, покинувшей группу, закрывается после завершения команд,
+	// подписок и выделенных соединений, начатых через Client. Потоки
+	// DoStream и DoMultiStream и клиенты из Nodes этим не удерживаются.
+	Client struct {
+		cobweb *Cobweb
+	}
+)
+
+// NewClient создаёт cobweb и возвращает его в виде rueidis.Client. Close
+// клиента закрывает cobweb.
+func NewClient(config *Config) (*Client, error) {
+	cobweb, err := New(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return cobweb.Client(), nil
+}
+
+// Client возвращает cobweb в виде rueidis.Client.
+func (it *Cobweb) Client() *Client {
+	return &Client{
+		cobweb: it,
+	}
+}
+
+func (it *Client) B() rueidis.Builder {
+	return it.cobweb.fallback.B()
+}
+
+func (it *Client) Do(ctx context.Context, cmd rueidis.Completed) rueidis.RedisResult {
+	var exec Executor = WriteCmd{Cmd: cmd}
+	if cmd.IsReadOnly() {
+		exec = SingleCmd{Cmd: cmd}
+	}
+
+	results, err := it.cobweb.Execute(ctx, exec)
+	if err != nil || len(results) == 0 {
+		return it.cobweb.anyClient().Do(ctx, cmd)
+	}
+	return results[0]
+}
+
+func (it *Client) DoMulti(ctx context.Context, multi ...rueidis.Completed) []rueidis.RedisResult {
+	if len(multi) == 0 {
+		return nil
+	}
+
+	var exec Executor = MultiCmd{Cmds: multi}
+	if !readOnlyAll(multi) {
+		exec = WriteMultiCmd{Cmds: multi}
+	}
+
+	results, err := it.cobweb.Execute(ctx, exec)
+	if err != nil {
+		return it.cobweb.anyClient().DoMulti(ctx, multi...)
+	}
+	return results
+}
+
+func (it *Client) DoCache(ctx context.Context, cmd rueidis.Cacheable, ttl time.Duration) rueidis.RedisResult {
+	results, err := it.cobweb.Execute(ctx, CacheCmd{Cmd: rueidis.CT(cmd, ttl)})
+	if err != nil || len(results) == 0 {
+		return it.cobweb.anyClient().DoCache(ctx, cmd, ttl)
+	}
+	return results[0]
+}
+
+func (it *Client) DoMultiCache(ctx context.Context, multi ...rueidis.CacheableTTL) []rueidis.RedisResult {
+	if len(multi) == 0 {
+		return nil
+	}
+
+	results, err := it.cobweb.Execute(ctx, MultiCacheCmd{Cmds: multi})
+	if err != nil {
+		return it.cobweb.anyClient().DoMultiCache(ctx, multi...)
+	}
+	return results
+}
+
+func (it *Client) DoStream(ctx context.Context, cmd rueidis.Completed) rueidis.RedisResultStream {
+	var exec Executor = WriteCmd{Cmd: cmd}
+	if cmd.IsReadOnly() {
+		exec = SingleCmd{Cmd: cmd}
+	}
+	return it.client(exec).DoStream(ctx, cmd)
+}
+
+func (it *Client) DoMultiStream(ctx context.Context, multi ...rueidis.Completed) rueidis.MultiRedisResultStream {
+	var exec Executor = WriteMultiCmd{Cmds: multi}
+	if readOnlyAll(multi) {
+		exec = MultiCmd{Cmds: multi}
+	}
+	return it.client(exec).DoMultiStream(ctx, multi...)
+}
+
+func (it *Client) Receive(ctx context.Context, subscribe rueidis.Completed, fn func(msg rueidis.PubSubMessage)) error {
+	client, release := it.hold()
+	defer release()
+	return client.Receive(ctx, subscribe, fn)
+}
+
+func (it *Client) Dedicated(fn func(rueidis.DedicatedClient) error) error {
+	client, release := it.hold()
+	defer release()
+	return client.Dedicated(fn)
+}
+
+func (it *Client) Dedicate() (rueidis.DedicatedClient, func()) {
+	client, release := it.hold()
+	release = sync.OnceFunc(release)
+	dedicated, cancel := client.Dedicate()
+	return dedicated, func() {
+		cancel()
+		release()
+	}
+}
+
+// Nodes возвращает клиенты всех нод обеих групп по адресам.
+func (it *Client) Nodes() map[string]rueidis.Client {
+	result := make(map[string]rueidis.Client)
+	for _, n := range it.cobweb.replicas.Nodes() {
+		result[n.Address()] = n.Client()
+	}
+	for _, n := range it.cobweb.masters.Nodes() {
+		result[n.Address()] = n.Client()
+	}
+	return result
+}
+
+func (it *Client) Mode() rueidis.ClientMode {
+	return rueidis.ClientModeStandalone
+}
+
+// Close закрывает cobweb.
+func (it *Client) Close() {
+	it.cobweb.Close()
+}
+
+// client возвращает клиент ноды, на которую cobweb направил бы исполнитель.
+func (it *Client) client(exec Executor) rueidis.Client {
+	target := it.cobweb.writeTarget()
+	if _, ok := as[writer](exec); !ok {
+		target = it.cobweb.route(it.cobweb.settings.Load(), exec, nil)
+	}
+
+	if n, ok := it.cobweb.node(target); ok {
+		return n.Client()
+	}
+	return it.cobweb.anyClient()
+}
+
+// hold возвращает клиент мастера записи и удерживает его открытым до
+// вызова release, даже если мастер тем временем покинет группу.
+func (it *Client) hold() (rueidis.Client, func()) {
+	if n, ok := it.cobweb.node(it.cobweb.writeTarget()); ok && n.Hold() {
+		return n.Client(), n.Release
+	}
+	return it.cobweb.anyClient(), func() {}
+}
+
+// anyClient возвращает клиент мастера записи, а без мастеров — первой
+// реплики. Если обе группы пусты, возвращается клиент ноды, доступной при
+// создании cobweb.
+func (it *Cobweb) anyClient() rueidis.Client {
+	if nodes := it.masters.Nodes(); len(nodes) > 0 {
+		return nodes[0].Client()
+	}
+	if nodes := it.replicas.Nodes(); len(nodes) > 0 {
+		return nodes[0].Client()
+	}
+	return it.fallback
+}
+
+// readOnlyAll сообщает, что все команды пакета только читают.
+func readOnlyAll(cmds []rueidis.Completed) bool {
+	for _, cmd := range cmds {
+		if !cmd.IsReadOnly() {
+			return false
+		}
+	}
+	return true
+}
+
+var _ rueidis.Client = (*Client)(nil)
@@ -4,104 +4,581 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"math"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/kuroko-shirai/axolotl/v1/cluster"
+	"github.com/kuroko-shirai/axolotl/v1/internal/node"
 	"github.com/redis/rueidis"
 )
 
 var (
-	ErrWriteCommand = errors.New("non-read command routed to cobweb")
+	ErrWriteCommand     = errors.New("non-read command routed to cobweb")
+	ErrNoTarget         = errors.New("routing policy returned no node")
+	ErrNoNodes          = errors.New("no master or replica nodes")
+	ErrNilMonitor       = errors.New("nil monitor")
+	ErrInvalidThreshold = errors.New("invalid threshold")
 )
 
 type (
+	// WriteCommandError описывает команду записи, отклонённую исполнителем
+	// чтения. Сопоставляется с ErrWriteCommand через errors.Is.
+	WriteCommandError struct {
+		Index   int    // Позиция команды в пакете.
+		Command string // Имя команды.
+	}
+
+	// ConfigError описывает ошибку в поле конфигурации. Сопоставляется с
+	// ErrNoNodes, ErrNilMonitor, ErrInvalidThreshold и другими причинами
+	// через errors.Is.
+	ConfigError struct {
+		Field string // Поле конфигурации, например Masters.MaxThreshold.
+		Err   error  // Причина.
+	}
+
 	// Monitor интерфейс мониторинга cpu master- и replica-нод системы.
 	Monitor interface {
 		Snapshot() map[string]float64 // Метод снятия текущей нагрузки системы.
 	}
 
+	// Config настройки cobweb. Одна из групп может отсутствовать (nil или
+	// пустой список адресов): тогда чтение и запись идут только на
+	// оставшуюся группу.
 	Config struct {
 		Masters  *cluster.Config
 		Replicas *cluster.Config
 		Monitor  Monitor
+		Policy   Policy // Политика маршрутизации чтения; по умолчанию &ThresholdMedian{}.
+		MaxLag   Lag    // Допустимое отставание реплик; исполнитель может переопределить через Stale.
+		// Discovery источник топологии. Если задан, адреса групп берутся из
+		// него и обновляются при каждом изменении топологии.
+		Discovery Discovery
+		Breaker   Breaker      // Размыкатель цепи для чтения с нод; по умолчанию выключен.
+		Retry     Retry        // Повтор чтения на другой ноде; по умолчанию без повторов.
+		Hedge     Hedge        // Хеджирование одиночных чтений; по умолчанию выключено.
+		Observer  Observer     // Наблюдатель маршрутизации и выполнения, например метрики.
+		Tracer    Tracer       // Трассировка вызовов Execute; по умолчанию выключена.
+		Logger    *slog.Logger // Журнал; по умолчанию slog.Default().
+		// MaxAge максимальный возраст измерения загрузки. Более старые
+		// измерения игнорируются, и нода считается нодой без данных. Требует
+		// монитора, реализующего SampleMonitor; 0 — без ограничения.
+		MaxAge time.Duration
+	}
+
+	// Discovery источник топологии master- и replica-нод.
+	Discovery interface {
+		Topology() cluster.Topology   // Текущая раскладка нод по ролям.
+		Watch(func(cluster.Topology)) // Подписка на изменения раскладки.
 	}
 
+	// core пороги группы нод.
 	core struct {
-		addresses []string
-		threshold float64
-		cluster   cluster.Cluster
+		threshold     float64
+		exitThreshold float64
+		minDwell      time.Duration
+	}
+
+	// settings настройки маршрутизации, которые Update заменяет атомарно.
+	settings struct {
+		masters  core
+		replicas core
+		monitor  Monitor
+		policy   Policy
+		maxLag   Lag
+		breaker  Breaker
+		maxAge   time.Duration
+		retry    Retry
+		hedge    Hedge
+		observer Observer
+		tracer   Tracer
+		logger   *slog.Logger
 	}
 
 	Cobweb struct {
-		Masters  core
-		Replicas core
-		Monitor  Monitor
+		masters   cluster.Cluster
+		replicas  cluster.Cluster
+		discovery Discovery
+		settings  atomic.Pointer[settings]
+		breakers  sync.Map       // Адрес ноды -> *breaker.
+		fallback  rueidis.Client // Клиент ноды, доступной при создании.
+		routing   atomic.Pointer[routing]
+		fresh     atomic.Pointer[fresh]
+
+		retryBudget budget
+		hedgeBudget budget
+		latencies   latencies
 	}
 )
 
-func New(config *Config) (Cobweb, error) {
-	if len(config.Masters.Addresses) == 0 && len(config.Replicas.Addresses) == 0 {
-		log.Fatal("incorrect system's configuration with empty nodes")
+func New(config *Config) (*Cobweb, error) {
+	if config == nil {
+		return nil, &ConfigError{Field: "Config", Err: ErrNoNodes}
 	}
 
-	if config.Monitor == nil {
-		log.Fatal("incorrect system's configuration with empty monitor")
+	config = config.normalize()
+	if config.Discovery != nil {
+		config = withTopology(config, config.Discovery.Topology())
+	}
+
+	if err := config.validate(); err != nil {
+		return nil, err
 	}
 
 	masters, err := cluster.NewMasters(config.Masters)
 	if err != nil {
-		return Cobweb{}, fmt.Errorf("failed to create masters-cluster: %v", err)
+		return nil, fmt.Errorf("failed to create masters-cluster: %w", err)
 	}
 
 	replicas, err := cluster.NewReplicas(config.Replicas)
 	if err != nil {
-		return Cobweb{}, fmt.Errorf("failed to create replicas-cluster: %v", err)
+		masters.Close()
+		return nil, fmt.Errorf("failed to create replicas-cluster: %w", err)
+	}
+
+	policy := config.Policy
+	if policy == nil {
+		policy = &ThresholdMedian{}
+	}
+
+	cobweb := &Cobweb{
+		masters:   masters,
+		replicas:  replicas,
+		discovery: config.Discovery,
+	}
+	cobweb.settings.Store(newSettings(config, policy))
+	cobweb.fallback = cobweb.anyClient()
+
+	if config.Discovery != nil {
+		config.Discovery.Watch(func(topology cluster.Topology) {
+			if err := cobweb.SetTopology(topology); err != nil {
+				cobweb.settings.Load().logger.Error("failed to apply topology", "error", err)
+			}
+		})
 	}
 
-	return Cobweb{
-		Masters: core{
-			addresses: config.Masters.Addresses,
-			threshold: config.Masters.MaxThreshold,
-			cluster:   masters,
-		},
-		Replicas: core{
-			addresses: config.Replicas.Addresses,
-			threshold: config.Replicas.MaxThreshold,
-			cluster:   replicas,
-		},
-		Monitor: config.Monitor,
-	}, nil
+	return cobweb, nil
 }
 
-func (it *Cobweb) Execute(ctx context.Context, exec Executor) ([]rueidis.RedisResult, error) {
-	snapshot := it.Monitor.Snapshot()
-	replicasMedian := it.median(snapshot, it.Replicas.addresses)
-	mastersMedian := it.median(snapshot, it.Masters.addresses)
-
-	var client rueidis.Client
-	if replicasMedian > it.Replicas.threshold {
-		if mastersMedian <= it.Masters.threshold {
-			// Мастера в среднем свободны — читаем с них
-			client = it.Masters.cluster.Client()
-		} else {
-			// Обе группы "в среднем" перегружены — читаем с реплик (меньше влияние на запись)
-			client = it.Replicas.cluster.Client()
+// newSettings собирает настройки маршрутизации из конфигурации.
+func newSettings(config *Config, policy Policy) *settings {
+	observer := config.Observer
+	if observer == nil {
+		observer = nopObserver{}
+	}
+
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &settings{
+		masters:  newCore(config.Masters),
+		replicas: newCore(config.Replicas),
+		monitor:  config.Monitor,
+		policy:   policy,
+		maxLag:   config.MaxLag,
+		breaker:  config.Breaker,
+		maxAge:   config.MaxAge,
+		retry:    config.Retry,
+		hedge:    config.Hedge,
+		observer: observer,
+		tracer:   config.Tracer,
+		logger:   logger,
+	}
+}
+
+func newCore(config *cluster.Config) core {
+	return core{
+		threshold:     config.MaxThreshold,
+		exitThreshold: config.ExitThreshold,
+		minDwell:      config.MinDwell,
+	}
+}
+
+// normalize возвращает копию конфигурации, в которой отсутствующая группа
+// заменена пустой.
+func (it *Config) normalize() *Config {
+	result := *it
+	if result.Masters == nil {
+		result.Masters = &cluster.Config{}
+	}
+	if result.Replicas == nil {
+		result.Replicas = &cluster.Config{}
+	}
+	return &result
+}
+
+// validate проверяет нормализованную конфигурацию.
+func (it *Config) validate() error {
+	if it.Monitor == nil {
+		return &ConfigError{Field: "Monitor", Err: ErrNilMonitor}
+	}
+
+	if len(it.Masters.Addresses) == 0 && len(it.Replicas.Addresses) == 0 {
+		return &ConfigError{Field: "Masters, Replicas", Err: ErrNoNodes}
+	}
+
+	if err := validateGroup("Masters", it.Masters); err != nil {
+		return err
+	}
+
+	if err := validateGroup("Replicas", it.Replicas); err != nil {
+		return err
+	}
+
+	if err := it.Breaker.validate(); err != nil {
+		return &ConfigError{Field: "Breaker", Err: err}
+	}
+
+	if err := it.Retry.validate(); err != nil {
+		return &ConfigError{Field: "Retry", Err: err}
+	}
+
+	if err := it.Hedge.validate(); err != nil {
+		return &ConfigError{Field: "Hedge", Err: err}
+	}
+
+	if it.MaxAge < 0 {
+		return &ConfigError{Field: "MaxAge", Err: errors.New("must not be negative")}
+	}
+
+	return nil
+}
+
+// validateGroup проверяет пороги группы.
+func validateGroup(name string, config *cluster.Config) error {
+	if invalidThreshold(config.MaxThreshold) {
+		return &ConfigError{
+			Field: name + ".MaxThreshold",
+			Err:   fmt.Errorf("%w: %v", ErrInvalidThreshold, config.MaxThreshold),
 		}
+	}
+
+	if invalidThreshold(config.ExitThreshold) {
+		return &ConfigError{
+			Field: name + ".ExitThreshold",
+			Err:   fmt.Errorf("%w: %v", ErrInvalidThreshold, config.ExitThreshold),
+		}
+	}
+
+	if config.MinDwell < 0 {
+		return &ConfigError{Field: name + ".MinDwell", Err: errors.New("must not be negative")}
+	}
+
+	return nil
+}
+
+// invalidThreshold сообщает, что порог не является неотрицательным числом.
+func invalidThreshold(threshold float64) bool {
+	return threshold < 0 || math.IsNaN(threshold) || math.IsInf(threshold, 0)
+}
+
+// withTopology возвращает копию конфигурации с адресами групп из раскладки.
+func withTopology(config *Config, topology cluster.Topology) *Config {
+	masters, replicas := *config.Masters, *config.Replicas
+	masters.Addresses, replicas.Addresses = topology.Masters, topology.Replicas
+
+	result := *config
+	result.Masters, result.Replicas = &masters, &replicas
+	return &result
+}
+
+// Update на лету применяет новую конфигурацию: открывает клиенты к новым
+// нодам, исключённые ноды закрывает после того, как завершатся уже начатые
+// на них чтения, и атомарно заменяет пороги, монитор, политику и допустимое
+// отставание. Policy == nil оставляет текущую политику вместе с её
+// состоянием. Если при создании задан Discovery, адреса групп по-прежнему
+// берутся из него, а поле Discovery новой конфигурации не учитывается.
+// Учётные данные групп применяются только при создании. Если к какой-либо
+// новой ноде подключиться не удалось, не меняются ни группы, ни настройки.
+func (it *Cobweb) Update(config *Config) error {
+	if config == nil {
+		return &ConfigError{Field: "Config", Err: ErrNoNodes}
+	}
+
+	config = config.normalize()
+	if it.discovery != nil {
+		config = withTopology(config, it.discovery.Topology())
+	}
+
+	if err := config.validate(); err != nil {
+		return err
+	}
+
+	policy := config.Policy
+	if policy == nil {
+		policy = it.settings.Load().policy
+	}
+	apply := func() {
+		it.settings.Store(newSettings(config, policy))
+	}
+
+	if it.discovery != nil {
+		apply()
+		return nil
+	}
+
+	return it.setTopology(cluster.Topology{
+		Masters:  config.Masters.Addresses,
+		Replicas: config.Replicas.Addresses,
+	}, apply)
+}
+
+// SetTopology приводит состав групп к раскладке: открывает клиенты к новым
+// нодам и закрывает клиенты к исключённым. Если к какой-либо новой ноде
+// подключиться не удалось, не меняется ни одна группа.
+func (it *Cobweb) SetTopology(topology cluster.Topology) error {
+	return it.setTopology(topology, nil)
+}
+
+// setTopology сначала открывает клиенты к новым нодам обеих групп и только
+// затем заменяет их состав, а вслед за ним вызывает apply, если он задан.
+func (it *Cobweb) setTopology(topology cluster.Topology, apply func()) error {
+	commitMasters, abortMasters, err := it.masters.Prepare(topology.Masters)
+	if err != nil {
+		return fmt.Errorf("failed to sync masters-cluster: %w", err)
+	}
+
+	commitReplicas, _, err := it.replicas.Prepare(topology.Replicas)
+	if err != nil {
+		abortMasters()
+		return fmt.Errorf("failed to sync replicas-cluster: %w", err)
+	}
+
+	commitMasters()
+	commitReplicas()
+	if apply != nil {
+		apply()
+	}
+
+	return nil
+}
+
+// Close закрывает клиенты всех нод.
+func (it *Cobweb) Close() {
+	it.masters.Close()
+	it.replicas.Close()
+}
+
+// Execute выполняет исполнитель, оборачивая вызов в span трассировщика,
+// если он задан.
+func (it *Cobweb) Execute(ctx context.Context, exec Executor) ([]rueidis.RedisResult, error) {
+	settings := it.settings.Load()
+	if settings.tracer == nil {
+		return it.execute(ctx, settings, exec, nopSpan{})
+	}
+
+	call := Call{
+		Executor: executorName(exec),
+		Medians:  it.Medians(),
+	}
+	if n, ok := as[named](exec); ok {
+		call.Commands = n.names()
+	}
+
+	ctx, span := settings.tracer.Start(ctx, call)
+	results, err := it.execute(ctx, settings, exec, span)
+	if err != nil {
+		span.End(err)
 	} else {
-		// Реплики в среднем свободны — читаем с них
-		client = it.Replicas.cluster.Client()
+		span.End(firstError(results))
 	}
 
-	return exec.Execute(ctx, client)
+	return results, err
 }
 
-func (it *Cobweb) median(snapshot map[string]float64, addresses []string) float64 {
-	cpus := make([]float64, 0, len(addresses))
-	for _, addr := range addresses {
-		if cpu, ok := snapshot[addr]; ok {
-			cpus = append(cpus, cpu)
+// execute маршрутизирует и выполняет исполнитель.
+func (it *Cobweb) execute(ctx context.Context, settings *settings, exec Executor, span Span) ([]rueidis.RedisResult, error) {
+	if _, ok := as[writer](exec); ok {
+		target := it.writeTarget()
+		n, ok := it.node(target)
+		if !ok {
+			return nil, ErrNoTarget
+		}
+
+		it.notify(settings, span, target, ReasonWrite)
+		start := time.Now()
+		results, err := executeOn(ctx, exec, n)
+		it.report(settings, exec, target, time.Since(start), results, err)
+		if hm, ok := settings.monitor.(HealthMonitor); ok && err == nil && !callerDone(ctx, failure(results)) {
+			hm.Report(target.Address, failure(results))
 		}
+		return results, err
+	}
+
+	if s, ok := as[scatterer](exec); ok {
+		return it.scatter(ctx, settings, span, exec, s.scatter())
+	}
+
+	retry := settings.retry
+	if r, ok := as[retrying](exec); ok {
+		retry = r.retry()
 	}
+	if retry.Budget > 0 {
+		it.retryBudget.deposit(retry.Budget)
+	}
+	if retry.Attempts > 0 || settings.hedge.Budget > 0 {
+		exec = pin(exec)
+	}
+
+	var (
+		exclude []string
+		results []rueidis.RedisResult
+		err     error
+	)
+	for attempt := 0; ; {
+		target := it.route(settings, exec, exclude)
+		n, ok := it.node(target)
+		// Собственная политика может вернуть уже исключённую ноду: выбор
+		// на ней повторялся бы без конца.
+		if !ok || slices.Contains(exclude, target.Address) {
+			if attempt > 0 {
+				// Повторять негде — возвращаем последний результат.
+				return results, err
+			}
+			return nil, ErrNoTarget
+		}
+
+		// Пробные запросы полуоткрытого размыкателя заняты другими
+		// чтениями — выбираем другую ноду.
+		if !it.acquire(settings, target.Address) {
+			exclude = append(exclude, target.Address)
+			continue
+		}
+
+		reason := ReasonPolicy
+		if attempt > 0 {
+			reason = ReasonRetry
+		}
+		it.notify(settings, span, target, reason)
+
+		result := it.run(ctx, settings, span, exec, target, n, exclude)
+		if errors.Is(result.err, rueidis.ErrClosing) {
+			// Нода покинула группу между выбором и отправкой — выбираем
+			// другую.
+			exclude = append(exclude, result.target.Address)
+			continue
+		}
+		target, results, err = result.target, result.results, result.err
+		it.report(settings, exec, target, result.elapsed, results, err)
+
+		if err != nil || attempt >= retry.Attempts || !retriable(exec, results) || !it.allowRetry(retry) {
+			return results, err
+		}
+		attempt++
+
+		settings.logger.Debug("retrying read", "address", target.Address, "attempt", attempt, "error", failure(results))
+
+		exclude = append(exclude, target.Address)
+		if retry.Alternate {
+			exclude = append(exclude, it.group(target.Group).Addresses()...)
+		}
 
-	return median(cpus)
+		if err := sleep(ctx, retry.delay(attempt)); err != nil {
+			return results, nil
+		}
+	}
+}
+
+// route выбирает ноду для чтения среди пригодных, кроме exclude.
+func (it *Cobweb) route(settings *settings, exec Executor, exclude []string) Target {
+	full := it.layout(settings)
+	layout := it.eligible(settings, full, exec, exclude)
+	layout.Masters.Members = full.Masters.Addresses
+	layout.Replicas.Members = full.Replicas.Addresses
+
+	if view := it.freshView(settings); view != nil {
+		return settings.policy.Route(view.Loads, it.withStats(view, full, layout))
+	}
+	return settings.policy.Route(it.snapshot(settings), layout)
+}
+
+func (it *WriteCommandError) Error() string {
+	return fmt.Sprintf("%v: %s at index %d", ErrWriteCommand, it.Command, it.Index)
+}
+
+func (it *WriteCommandError) Unwrap() error {
+	return ErrWriteCommand
+}
+
+func (it *ConfigError) Error() string {
+	return fmt.Sprintf("invalid cobweb config: %s: %v", it.Field, it.Err)
+}
+
+func (it *ConfigError) Unwrap() error {
+	return it.Err
+}
+
+// layout возвращает текущую раскладку нод по группам.
+func (it *Cobweb) layout(settings *settings) Layout {
+	return Layout{
+		Masters:  settings.masters.layout(it.masters.Addresses()),
+		Replicas: settings.replicas.layout(it.replicas.Addresses()),
+	}
+}
+
+// State возвращает текущее состояние маршрутизации, если политика его
+// хранит.
+func (it *Cobweb) State() (State, bool) {
+	stateful, ok := it.settings.Load().policy.(Stateful)
+	if !ok {
+		return State{}, false
+	}
+	return stateful.State(), true
+}
+
+// writeTarget возвращает мастер для команд записи. Мастера standalone-нод
+// независимы друг от друга, поэтому запись всегда идёт на первый мастер из
+// конфигурации, а не на наименее загруженный.
+func (it *Cobweb) writeTarget() Target {
+	addresses := it.masters.Addresses()
+	if len(addresses) == 0 {
+		return Target{}
+	}
+
+	return Target{
+		Group:   GroupMasters,
+		Address: addresses[0],
+	}
+}
+
+// group возвращает группу нод.
+func (it *Cobweb) group(group Group) cluster.Cluster {
+	if group == GroupMasters {
+		return it.masters
+	}
+	return it.replicas
+}
+
+// executeOn выполняет исполнитель на ноде. Клиент ноды остаётся открытым до
+// конца выполнения, даже если нода тем временем покинула группу.
+func executeOn(ctx context.Context, exec Executor, n node.Node) ([]rueidis.RedisResult, error) {
+	if !n.Hold() {
+		return nil, rueidis.ErrClosing
+	}
+	defer n.Release()
+
+	return exec.Execute(ctx, n.Client())
+}
+
+// node возвращает ноду, выбранную политикой маршрутизации.
+func (it *Cobweb) node(target Target) (node.Node, bool) {
+	if target.Address == "" {
+		return node.Node{}, false
+	}
+
+	return it.group(target.Group).Node(target.Address)
+}
+
+func (it core) layout(addresses []string) GroupLayout {
+	return GroupLayout{
+		Addresses:     addresses,
+		Threshold:     it.threshold,
+		ExitThreshold: it.exitThreshold,
+		MinDwell:      it.minDwell,
+	}
 }
@@ -0,0 +1,121 @@
+package cobweb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kuroko-shirai/axolotl/v1/internal/stats"
+	"github.com/kuroko-shirai/axolotl/v1/monitor"
+	"github.com/redis/rueidis"
+)
+
+var (
+	ErrNotWrite      = errors.New("tracked execution requires a write executor")
+	ErrNotReplicated = errors.New("write was not acknowledged by enough replicas")
+)
+
+type (
+	// Token маркер согласованности: смещение репликации мастера сразу после
+	// записи.
+	Token struct {
+		Master string // Адрес мастера, принявшего запись.
+		ReplID string // master_replid мастера: идентификатор истории репликации.
+		Offset int64  // master_repl_offset после записи.
+	}
+
+	// Wait ожидание подтверждения записи репликами командой WAIT.
+	Wait struct {
+		Replicas int64
+		Timeout  time.Duration
+	}
+
+	// Consistent читает только с реплик того же мастера, которые уже догнали
+	// Token, а если таких нет — с самого мастера.
+	Consistent struct {
+		Executor
+		Token Token
+	}
+
+	// consistent исполнитель, которому нужно чтение не старее маркера.
+	consistent interface {
+		token() Token
+	}
+)
+
+func (it Consistent) token() Token {
+	return it.Token
+}
+
+func (it Consistent) unwrap() Executor {
+	return it.Executor
+}
+
+// ExecuteTracked выполняет исполнитель записи и возвращает маркер
+// согласованности для последующих чтений через Consistent. Запись, WAIT
+// (если wait задан) и INFO replication уходят на мастер одним пакетом, поэтому
+// смещение снимается сразу после записи.
+func (it *Cobweb) ExecuteTracked(ctx context.Context, exec Executor, wait *Wait) ([]rueidis.RedisResult, Token, error) {
+	w, ok := as[writer](exec)
+	if !ok {
+		return nil, Token{}, ErrNotWrite
+	}
+
+	target := it.writeTarget()
+	n, ok := it.node(target)
+	if !ok {
+		return nil, Token{}, ErrNoTarget
+	}
+	if !n.Hold() {
+		return nil, Token{}, ErrNoTarget
+	}
+	defer n.Release()
+	client := n.Client()
+
+	cmds := w.commands()
+	batch := make([]rueidis.Completed, 0, len(cmds)+2)
+	batch = append(batch, cmds...)
+	if wait != nil {
+		batch = append(batch, client.B().Wait().Numreplicas(wait.Replicas).Timeout(wait.Timeout.Milliseconds()).Build())
+	}
+	batch = append(batch, client.B().Info().Section("replication").Build())
+
+	results := client.DoMulti(ctx, batch...)
+
+	infoStr, err := results[len(results)-1].ToString()
+	if err != nil {
+		return results[:len(cmds)], Token{}, fmt.Errorf("failed to get replication offset from %s: %w", target.Address, err)
+	}
+	replication := stats.NewReplication(infoStr)
+	token := Token{
+		Master: target.Address,
+		ReplID: replication.ReplID,
+		Offset: replication.MasterReplOffset,
+	}
+
+	if wait != nil {
+		acked, err := results[len(cmds)].AsInt64()
+		if err != nil {
+			return results[:len(cmds)], token, fmt.Errorf("WAIT command failed: %w", err)
+		}
+		if acked < wait.Replicas {
+			return results[:len(cmds)], token, fmt.Errorf("%w: %d of %d", ErrNotReplicated, acked, wait.Replicas)
+		}
+	}
+
+	return results[:len(cmds)], token, nil
+}
+
+// reached проверяет, что реплика того же мастера догнала маркер.
+// Смещения сравнимы только в пределах одной истории репликации, поэтому
+// реплики другого мастера не подходят. Реплика сообщает адрес мастера так,
+// как видит его сама, и он может не совпадать с адресом из конфигурации,
+// поэтому мастер сверяется по идентификатору репликации, а по адресу —
+// только для маркеров без него.
+func (it Token) reached(r monitor.Replication) bool {
+	if it.ReplID != "" {
+		return r.ID == it.ReplID && r.Offset >= it.Offset
+	}
+	return r.Master == it.Master && r.Offset >= it.Offset
+}
@@ -0,0 +1,128 @@
+package cobweb
+
+import (
+	"slices"
+	"time"
+
+	"github.com/kuroko-shirai/axolotl/v1/monitor"
+)
+
+type (
+	// ReplicationMonitor монитор, который также сообщает состояние
+	// репликации нод. Если Monitor его реализует, cobweb исключает из чтения
+	// отстающие реплики.
+	ReplicationMonitor interface {
+		Replication() map[string]monitor.Replication
+	}
+
+	// Lag допустимое отставание реплики от мастера. Нулевое поле не
+	// ограничивает соответствующую величину. Реплика с неизвестным
+	// отставанием в байтах (мастер не под мониторингом) при заданном Bytes
+	// не допускается.
+	Lag struct {
+		Bytes int64         // Отставание по смещению репликации.
+		Time  time.Duration // Время с последнего обмена с мастером.
+	}
+
+	// Stale задаёт для исполнителя собственное допустимое отставание реплик
+	// вместо Config.MaxLag.
+	Stale struct {
+		Executor
+		MaxLag Lag
+	}
+
+	// tolerant исполнитель с собственным допустимым отставанием реплик.
+	tolerant interface {
+		staleness() Lag
+	}
+)
+
+func (it Stale) staleness() Lag {
+	return it.MaxLag
+}
+
+func (it Stale) unwrap() Executor {
+	return it.Executor
+}
+
+// eligible возвращает раскладку без нод, непригодных для выполнения exec:
+// исключённых явно, неисправных по данным монитора, отсечённых размыкателем
+// и, для реплик, отстающих.
+func (it *Cobweb) eligible(settings *settings, layout Layout, exec Executor, exclude []string) Layout {
+	c, isConsistent := as[consistent](exec)
+	if isConsistent {
+		// Согласованное чтение возможно только с мастера, принявшего запись.
+		layout.Masters.Addresses = []string{c.token().Master}
+	}
+
+	latest := latest(settings)
+
+	var health map[string]monitor.Health
+	if latest != nil {
+		health = latest.Health
+	} else if hm, ok := settings.monitor.(HealthMonitor); ok {
+		health = hm.Health()
+	}
+	usable := func(address string) bool {
+		return !slices.Contains(exclude, address) &&
+			health[address] != monitor.Down &&
+			it.available(settings, address)
+	}
+
+	layout.Masters.Addresses = filter(layout.Masters.Addresses, usable)
+
+	rm, hasReplication := settings.monitor.(ReplicationMonitor)
+	hasReplication = hasReplication || latest != nil
+	if !hasReplication {
+		if isConsistent {
+			// Без данных о репликации нельзя проверить, что реплики догнали
+			// маркер.
+			layout.Replicas.Addresses = nil
+			return layout
+		}
+		layout.Replicas.Addresses = filter(layout.Replicas.Addresses, usable)
+		return layout
+	}
+
+	maxLag := settings.maxLag
+	if t, ok := as[tolerant](exec); ok {
+		maxLag = t.staleness()
+	}
+
+	var replication map[string]monitor.Replication
+	if latest != nil {
+		replication = latest.Replication
+	} else {
+		replication = rm.Replication()
+	}
+	layout.Replicas.Addresses = filter(layout.Replicas.Addresses, func(address string) bool {
+		if !usable(address) {
+			return false
+		}
+		r, ok := replication[address]
+		if isConsistent && (!ok || !c.token().reached(r)) {
+			return false
+		}
+		return !ok || maxLag.allows(r)
+	})
+
+	return layout
+}
+
+// allows проверяет, что отставание реплики укладывается в допустимое.
+// Реплика с разорванным соединением с мастером не допускается никогда.
+func (it Lag) allows(r monitor.Replication) bool {
+	if r.Role != monitor.RoleReplica {
+		return true
+	}
+	if !r.LinkUp {
+		return false
+	}
+	if it.Bytes > 0 && (r.LagBytes < 0 || r.LagBytes > it.Bytes) {
+		return false
+	}
+	if it.Time > 0 && r.LastIO > it.Time {
+		return false
+	}
+	return true
+}
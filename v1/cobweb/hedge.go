@@ -0,0 +1,247 @@
+package cobweb
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/kuroko-shirai/axolotl/v1/internal/node"
+	"github.com/redis/rueidis"
+)
+
+const (
+	defaultHedgePercentile = 95.0
+	latencyWindow          = 128 // Число последних чтений для оценки задержки.
+	minLatencySamples      = 16  // Минимум измерений, чтобы доверять перцентилю.
+)
+
+type (
+	// Hedge настройки хеджирования одиночных чтений (SingleCmd): если нода не
+	// ответила за Delay, то же чтение отправляется на вторую ноду, и
+	// побеждает первый ответ. Хедж не отправляется, если вторая нода по
+	// данным монитора перегружена.
+	Hedge struct {
+		// Budget доля хеджированных чтений, например 0.05 — не больше
+		// одного хеджа на двадцать чтений; 0 — хеджирование выключено.
+		Budget float64
+		// Delay задержка перед хеджем; 0 — перцентиль Percentile
+		// наблюдаемой задержки чтений.
+		Delay      time.Duration
+		Percentile float64 // По умолчанию 95.
+	}
+
+	// hedgeable исполнитель, чтение которого можно хеджировать.
+	hedgeable interface {
+		hedge()
+	}
+
+	// outcome результат чтения с одной ноды.
+	outcome struct {
+		target  Target
+		results []rueidis.RedisResult
+		err     error
+		elapsed time.Duration
+	}
+
+	// latencies скользящее окно задержек хеджируемых чтений.
+	latencies struct {
+		mu     sync.Mutex
+		values [latencyWindow]time.Duration
+		pos    int
+		full   bool
+	}
+)
+
+func (it SingleCmd) hedge() {}
+
+// validate проверяет настройки хеджирования.
+func (it Hedge) validate() error {
+	if it.Budget < 0 || it.Delay < 0 {
+		return errors.New("invalid hedge: must not be negative")
+	}
+	if it.Percentile < 0 || it.Percentile > 100 {
+		return errors.New("invalid hedge: percentile must be in [0, 100]")
+	}
+	return nil
+}
+
+func (it *latencies) add(d time.Duration) {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	it.values[it.pos] = d
+	it.pos++
+	if it.pos == len(it.values) {
+		it.pos, it.full = 0, true
+	}
+}
+
+// percentile возвращает перцентиль p задержек, если измерений достаточно.
+func (it *latencies) percentile(p float64) (time.Duration, bool) {
+	it.mu.Lock()
+	values := it.values[:it.pos]
+	if it.full {
+		values = it.values[:]
+	}
+	sorted := slices.Clone(values)
+	it.mu.Unlock()
+
+	if len(sorted) < minLatencySamples {
+		return 0, false
+	}
+	slices.Sort(sorted)
+
+	rank := int(p / 100 * float64(len(sorted)))
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank], true
+}
+
+// hedgeDelay возвращает задержку перед хеджем.
+func (it *Cobweb) hedgeDelay(hedge Hedge) (time.Duration, bool) {
+	if hedge.Delay > 0 {
+		return hedge.Delay, true
+	}
+
+	p := hedge.Percentile
+	if p == 0 {
+		p = defaultHedgePercentile
+	}
+	return it.latencies.percentile(p)
+}
+
+// run выполняет чтение на выбранной ноде, при необходимости хеджируя его
+// второй нодой, и возвращает результат победившей ноды.
+func (it *Cobweb) run(ctx context.Context, settings *settings, span Span, exec Executor, target Target, n node.Node, exclude []string) outcome {
+	_, canHedge := as[hedgeable](exec)
+	if !canHedge || settings.hedge.Budget == 0 {
+		result := it.call(ctx, exec, target, n)
+		if canHedge {
+			// Задержку хеджа задают только хеджируемые чтения: пакеты
+			// заметно дольше и завысили бы перцентиль.
+			it.latencies.add(result.elapsed)
+		}
+		it.settle(ctx, settings, result)
+		return result
+	}
+
+	it.hedgeBudget.deposit(settings.hedge.Budget)
+	delay, ok := it.hedgeDelay(settings.hedge)
+	if !ok {
+		result := it.call(ctx, exec, target, n)
+		it.latencies.add(result.elapsed)
+		it.settle(ctx, settings, result)
+		return result
+	}
+
+	outcomes := make(chan outcome, 2)
+	primaryCtx, cancelPrimary := context.WithCancel(ctx)
+	go func() {
+		outcomes <- it.call(primaryCtx, exec, target, n)
+	}()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	pending := map[string]context.CancelFunc{target.Address: cancelPrimary}
+	select {
+	case result := <-outcomes:
+		cancelPrimary()
+		it.latencies.add(result.elapsed)
+		it.settle(ctx, settings, result)
+		return result
+	case <-timer.C:
+	}
+
+	if second, secondNode, ok := it.hedgeTarget(settings, span, exec, append(slices.Clone(exclude), target.Address)); ok {
+		secondCtx, cancelSecond := context.WithCancel(ctx)
+		pending[second.Address] = cancelSecond
+		go func() {
+			outcomes <- it.call(secondCtx, exec, second, secondNode)
+		}()
+	}
+
+	winner, received := <-outcomes, 1
+	if received < len(pending) && (winner.err != nil || failure(winner.results) != nil) {
+		// Первой ответила неисправная нода, а вторая ещё читает — ждём её
+		// ответ вместо ошибки.
+		it.settle(ctx, settings, winner)
+		winner, received = <-outcomes, received+1
+	}
+	it.latencies.add(winner.elapsed)
+	it.settle(ctx, settings, winner)
+	for _, cancel := range pending {
+		cancel()
+	}
+
+	if received < len(pending) {
+		// Проигравшее чтение отменено: его результат не говорит о
+		// неисправности ноды, но занятое право на чтение нужно вернуть.
+		go func() {
+			loser := <-outcomes
+			if errors.Is(loser.err, context.Canceled) || failure(loser.results) == nil {
+				it.release(settings, loser.target.Address)
+				return
+			}
+			it.observe(ctx, settings, loser.target.Address, loser.results)
+		}()
+	}
+
+	return winner
+}
+
+// hedgeTarget выбирает вторую ноду для хеджа. Хедж не отправляется, если
+// бюджет исчерпан или вторая нода перегружена.
+func (it *Cobweb) hedgeTarget(settings *settings, span Span, exec Executor, exclude []string) (Target, node.Node, bool) {
+	target := it.route(settings, exec, exclude)
+	n, ok := it.node(target)
+	if !ok {
+		return Target{}, node.Node{}, false
+	}
+
+	threshold := settings.replicas.threshold
+	if target.Group == GroupMasters {
+		threshold = settings.masters.threshold
+	}
+	if load, ok := it.snapshot(settings)[target.Address]; ok && load > threshold {
+		return Target{}, node.Node{}, false
+	}
+
+	if !it.hedgeBudget.withdraw() {
+		return Target{}, node.Node{}, false
+	}
+
+	if !it.acquire(settings, target.Address) {
+		return Target{}, node.Node{}, false
+	}
+	it.notify(settings, span, target, ReasonHedge)
+	settings.logger.Debug("hedging read", "address", target.Address)
+
+	return target, n, true
+}
+
+// settle учитывает результат чтения в размыкателе и мониторе. Ошибка самого
+// вызова (нода покинула группу, исполнитель отклонил команду) о здоровье
+// ноды ничего не говорит: занятое право на чтение возвращается без отчёта.
+func (it *Cobweb) settle(ctx context.Context, settings *settings, result outcome) {
+	if result.err != nil {
+		it.release(settings, result.target.Address)
+		return
+	}
+	it.observe(ctx, settings, result.target.Address, result.results)
+}
+
+// call выполняет чтение на одной ноде и замеряет его длительность.
+func (it *Cobweb) call(ctx context.Context, exec Executor, target Target, n node.Node) outcome {
+	start := time.Now()
+	results, err := executeOn(ctx, exec, n)
+	return outcome{
+		target:  target,
+		results: results,
+		err:     err,
+		elapsed: time.Since(start),
+	}
+}
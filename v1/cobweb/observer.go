@@ -0,0 +1,101 @@
+package cobweb
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/redis/rueidis"
+)
+
+const (
+	ReasonWrite     Reason = "write"     // Запись на мастер.
+	ReasonPolicy    Reason = "policy"    // Выбор политики маршрутизации.
+	ReasonRetry     Reason = "retry"     // Повтор чтения на другой ноде.
+	ReasonHedge     Reason = "hedge"     // Хедж медленного чтения.
+	ReasonRebalance Reason = "rebalance" // Перенос подписки на другую ноду.
+)
+
+type (
+	// Reason причина выбора ноды.
+	Reason string
+
+	// Result итог выполнения исполнителя на ноде.
+	Result struct {
+		Target   Target
+		Executor string        // Имя стратегии, например SingleCmd.
+		Duration time.Duration // Длительность выполнения на ноде.
+		Err      error         // Ошибка выполнения или первая ошибка результатов.
+	}
+
+	// Medians медианы загрузки групп по данным монитора.
+	Medians struct {
+		Masters  float64
+		Replicas float64
+	}
+
+	// Observer получает события маршрутизации и выполнения. Методы
+	// вызываются синхронно на пути запроса и должны быть быстрыми.
+	Observer interface {
+		Route(target Target, reason Reason) // Нода выбрана для выполнения.
+		Result(result Result)               // Исполнитель завершился на ноде.
+	}
+
+	// nopObserver наблюдатель по умолчанию.
+	nopObserver struct{}
+)
+
+func (nopObserver) Route(Target, Reason) {}
+func (nopObserver) Result(Result)        {}
+
+// Medians возвращает текущие медианы загрузки групп.
+func (it *Cobweb) Medians() Medians {
+	settings := it.settings.Load()
+	snapshot := it.snapshot(settings)
+
+	return Medians{
+		Masters:  groupMedian(snapshot, it.masters.Addresses()),
+		Replicas: groupMedian(snapshot, it.replicas.Addresses()),
+	}
+}
+
+// report сообщает наблюдателю итог выполнения исполнителя на ноде.
+func (it *Cobweb) report(settings *settings, exec Executor, target Target, duration time.Duration, results []rueidis.RedisResult, err error) {
+	if err == nil {
+		err = firstError(results)
+	}
+
+	settings.observer.Result(Result{
+		Target:   target,
+		Executor: executorName(exec),
+		Duration: duration,
+		Err:      err,
+	})
+}
+
+// executorName возвращает имя стратегии без обёрток Stale, Consistent и
+// Retrying.
+func executorName(exec Executor) string {
+	for {
+		w, ok := exec.(wrapper)
+		if !ok {
+			break
+		}
+		exec = w.unwrap()
+	}
+
+	t := reflect.TypeOf(exec)
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Name()
+}
+
+// firstError возвращает первую ошибку результатов, кроме redis nil.
+func firstError(results []rueidis.RedisResult) error {
+	for _, result := range results {
+		if err := result.Error(); err != nil && !rueidis.IsRedisNil(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,306 @@
+package cobweb
+
+import (
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// fullLoad загрузка CPU ноды, при которой у неё не остаётся свободной ёмкости.
+const fullLoad = 100.0
+
+// hotMargin превышение загрузки над наименее загруженной нодой группы, при
+// котором нода считается заметно горячее остальных и не получает чтений.
+const hotMargin = 10.0
+
+const (
+	GroupReplicas Group = iota // Группа replica-нод.
+	GroupMasters               // Группа master-нод.
+)
+
+type (
+	// Group группа нод, на которую направляется чтение.
+	Group uint8
+
+	// GroupLayout описывает одну группу нод для политики маршрутизации.
+	GroupLayout struct {
+		Addresses []string // Адреса нод группы, из которых выбирается нода.
+		// Members все ноды группы, включая исключённые для этого вызова; по
+		// ним считается загрузка группы. nil — совпадает с Addresses.
+		Members       []string
+		Threshold     float64       // Порог, выше которого группа перегружена.
+		ExitThreshold float64       // Порог выхода из перегрузки; 0 — совпадает с Threshold.
+		MinDwell      time.Duration // Минимальное время чтения с группы после переключения.
+		// Stats предрасчитанные агрегаты Members; nil — политика считает их
+		// по снимку сама.
+		Stats *GroupStats
+	}
+
+	// Layout раскладка нод по группам.
+	Layout struct {
+		Masters  GroupLayout
+		Replicas GroupLayout
+	}
+
+	// Target нода, выбранная политикой маршрутизации.
+	Target struct {
+		Group   Group
+		Address string
+	}
+
+	// Policy политика выбора ноды для команды чтения.
+	Policy interface {
+		// Route выбирает ноду по снимку загрузки и раскладке групп.
+		// Пустой Address означает, что подходящей ноды нет; уже
+		// опробованная в этом вызове нода, которой нет в layout, тоже
+		// означает отсутствие ноды. Снимок нельзя изменять.
+		Route(snapshot map[string]float64, layout Layout) Target
+	}
+
+	// State состояние маршрутизации: группа, с которой идёт чтение, и момент
+	// переключения на неё.
+	State struct {
+		Group Group
+		Since time.Time
+	}
+
+	// Stateful политика, которая хранит состояние маршрутизации между
+	// вызовами.
+	Stateful interface {
+		State() State
+	}
+
+	// ThresholdMedian сравнивает медианы групп с их порогами: читает с реплик,
+	// пока они в среднем свободны, иначе с мастеров, если свободны они.
+	// Внутри группы чтения распределяются по нодам не выше порога группы и
+	// не заметно горячее наименее загруженной.
+	//
+	// Перегрузка группы определяется с гистерезисом (Threshold на вход,
+	// ExitThreshold на выход), а группа не меняется раньше MinDwell после
+	// переключения на неё. Нулевое значение готово к использованию.
+	ThresholdMedian struct {
+		mu                 sync.Mutex
+		state              State
+		started            bool
+		replicasOverloaded bool
+		mastersOverloaded  bool
+	}
+
+	// LeastLoaded выбирает наименее загруженную ноду среди всех групп.
+	LeastLoaded struct{}
+
+	// PowerOfTwo выбирает две случайные ноды и читает с менее загруженной.
+	PowerOfTwo struct{}
+
+	// WeightedRandom выбирает случайную ноду с весом, пропорциональным
+	// свободной ёмкости CPU.
+	WeightedRandom struct{}
+
+	// AlwaysReplica всегда читает с реплик, распределяя чтения так же, как
+	// ThresholdMedian внутри группы.
+	AlwaysReplica struct{}
+)
+
+func (it Group) String() string {
+	switch it {
+	case GroupMasters:
+		return "masters"
+	case GroupReplicas:
+		return "replicas"
+	default:
+		return "unknown"
+	}
+}
+
+// group возвращает описание группы.
+func (it Layout) group(group Group) GroupLayout {
+	if group == GroupMasters {
+		return it.Masters
+	}
+	return it.Replicas
+}
+
+// members возвращает все ноды группы.
+func (it GroupLayout) members() []string {
+	if it.Members == nil {
+		return it.Addresses
+	}
+	return it.Members
+}
+
+// median возвращает медиану загрузки всех нод группы.
+func (it GroupLayout) median(snapshot map[string]float64) float64 {
+	if it.Stats != nil {
+		return it.Stats.Median
+	}
+	return groupMedian(snapshot, it.members())
+}
+
+// pick выбирает ноду среди адресов группы, распределяя чтения по нодам не
+// выше порога и не заметно горячее наименее загруженной.
+func (it GroupLayout) pick(snapshot map[string]float64) string {
+	if it.Stats != nil && it.Stats.Least != "" && same(it.Addresses, it.members()) {
+		if least, ok := snapshot[it.Stats.Least]; ok {
+			return spread(snapshot, it.Addresses, it.Threshold, least, true)
+		}
+	}
+	least, found := minLoad(snapshot, it.Addresses)
+	return spread(snapshot, it.Addresses, it.Threshold, least, found)
+}
+
+// size возвращает число нод раскладки в обеих группах.
+func (it Layout) size() int {
+	return len(it.Replicas.Addresses) + len(it.Masters.Addresses)
+}
+
+// at возвращает i-ю ноду раскладки: сначала реплики, затем мастера.
+func (it Layout) at(i int) Target {
+	if i < len(it.Replicas.Addresses) {
+		return Target{Group: GroupReplicas, Address: it.Replicas.Addresses[i]}
+	}
+	return Target{Group: GroupMasters, Address: it.Masters.Addresses[i-len(it.Replicas.Addresses)]}
+}
+
+// Route считает перегрузку групп по всем их нодам, поэтому исключения
+// отдельного вызова (повтор, хедж, согласованное чтение) не меняют общее
+// состояние. Если в выбранной группе не осталось адресов, нода берётся из
+// другой группы без переключения состояния.
+func (it *ThresholdMedian) Route(snapshot map[string]float64, layout Layout) Target {
+	var group Group
+	switch {
+	case len(layout.Replicas.members()) == 0:
+		group = GroupMasters
+	case len(layout.Masters.members()) == 0:
+		group = GroupReplicas
+	default:
+		group = it.update(snapshot, layout)
+	}
+
+	if len(layout.group(group).Addresses) == 0 {
+		group = other(group)
+	}
+
+	return Target{
+		Group:   group,
+		Address: layout.group(group).pick(snapshot),
+	}
+}
+
+// update пересчитывает перегрузку групп и возвращает группу, с которой
+// нужно читать.
+func (it *ThresholdMedian) update(snapshot map[string]float64, layout Layout) Group {
+	replicasMedian := layout.Replicas.median(snapshot)
+	mastersMedian := layout.Masters.median(snapshot)
+	now := time.Now()
+
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	if !it.started {
+		it.state = State{Group: GroupReplicas, Since: now}
+		it.started = true
+	}
+
+	it.replicasOverloaded = overloaded(it.replicasOverloaded, replicasMedian, layout.Replicas)
+	it.mastersOverloaded = overloaded(it.mastersOverloaded, mastersMedian, layout.Masters)
+
+	// Мастера в среднем свободны — читаем с них.
+	// Если обе группы "в среднем" перегружены — остаёмся на репликах
+	// (меньше влияние на запись).
+	want := GroupReplicas
+	if it.replicasOverloaded && !it.mastersOverloaded {
+		want = GroupMasters
+	}
+
+	if want != it.state.Group && now.Sub(it.state.Since) >= layout.group(it.state.Group).MinDwell {
+		it.state = State{Group: want, Since: now}
+	}
+	return it.state.Group
+}
+
+func (it *ThresholdMedian) State() State {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	if !it.started {
+		return State{Group: GroupReplicas}
+	}
+	return it.state
+}
+
+func (it LeastLoaded) Route(snapshot map[string]float64, layout Layout) Target {
+	var (
+		best    Target
+		bestCPU = -1.0
+	)
+	for i := range layout.size() {
+		candidate := layout.at(i)
+		if best.Address == "" {
+			best = candidate
+		}
+		cpu, ok := snapshot[candidate.Address]
+		if !ok {
+			continue
+		}
+		if bestCPU < 0 || cpu < bestCPU {
+			best, bestCPU = candidate, cpu
+		}
+	}
+
+	return best
+}
+
+func (it PowerOfTwo) Route(snapshot map[string]float64, layout Layout) Target {
+	size := layout.size()
+	switch size {
+	case 0:
+		return Target{}
+	case 1:
+		return layout.at(0)
+	}
+
+	i := rand.IntN(size)
+	j := rand.IntN(size - 1)
+	if j >= i {
+		j++
+	}
+
+	first, second := layout.at(i), layout.at(j)
+	firstCPU, firstOk := snapshot[first.Address]
+	secondCPU, secondOk := snapshot[second.Address]
+	if !firstOk || (secondOk && secondCPU < firstCPU) {
+		return second
+	}
+	return first
+}
+
+func (it WeightedRandom) Route(snapshot map[string]float64, layout Layout) Target {
+	size := layout.size()
+	if size == 0 {
+		return Target{}
+	}
+
+	total := 0.0
+	for i := range size {
+		total += freeCapacity(snapshot, layout.at(i).Address)
+	}
+
+	point := rand.Float64() * total
+	for i := range size {
+		candidate := layout.at(i)
+		weight := freeCapacity(snapshot, candidate.Address)
+		if point < weight {
+			return candidate
+		}
+		point -= weight
+	}
+
+	return layout.at(size - 1)
+}
+
+func (it AlwaysReplica) Route(snapshot map[string]float64, layout Layout) Target {
+	return Target{
+		Group:   GroupReplicas,
+		Address: layout.Replicas.pick(snapshot),
+	}
+}
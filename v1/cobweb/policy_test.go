@@ -0,0 +1,26 @@
+package cobweb
+
+import "testing"
+
+func TestSpread(t *testing.T) {
+	snapshot := map[string]float64{"a": 20, "b": 24, "c": 45, "d": 31}
+	addresses := []string{"a", "b", "c", "d"}
+
+	// c выше порога, d заметно горячее a: чтения делятся между a и b.
+	picked := make(map[string]int)
+	for range 1000 {
+		picked[spread(snapshot, addresses, 40, 20, true)]++
+	}
+	if len(picked) != 2 || picked["a"] == 0 || picked["b"] == 0 {
+		t.Fatalf("picked = %v, want reads spread over a and b", picked)
+	}
+
+	// Без данных мониторинга выбор идёт по всем адресам.
+	picked = make(map[string]int)
+	for range 1000 {
+		picked[spread(nil, addresses, 40, 0, false)]++
+	}
+	if len(picked) != len(addresses) {
+		t.Fatalf("picked = %v, want all addresses without data", picked)
+	}
+}
@@ -0,0 +1,132 @@
+package cobweb
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/redis/rueidis"
+)
+
+// maxBudgetTokens запас бюджета дополнительных запросов, который копится за
+// время спокойной работы.
+const maxBudgetTokens = 10.0
+
+type (
+	// Retry настройки повтора чтения на другой ноде после ошибки, которую
+	// исполнитель признал повторяемой.
+	Retry struct {
+		Attempts   int           // Число повторов одного чтения; 0 — без повторов.
+		Backoff    time.Duration // Пауза перед первым повтором, далее удваивается.
+		MaxBackoff time.Duration // Верхняя граница паузы; 0 — без ограничения.
+		// Alternate направляет повтор на другую группу нод, а не на другую
+		// ноду той же группы.
+		Alternate bool
+		// Budget доля повторов от числа чтений, например 0.1 — не больше
+		// одного повтора на десять чтений; 0 — без ограничения.
+		Budget float64
+	}
+
+	// Retrier исполнитель, который решает, какие результаты стоит повторить
+	// на другой ноде.
+	Retrier interface {
+		Retriable(results []rueidis.RedisResult) bool
+	}
+
+	// Retrying задаёт для исполнителя собственные настройки повтора вместо
+	// Config.Retry.
+	Retrying struct {
+		Executor
+		Retry Retry
+	}
+
+	// retrying исполнитель с собственными настройками повтора.
+	retrying interface {
+		retry() Retry
+	}
+
+	// budget ограничивает долю дополнительных запросов: каждое чтение
+	// пополняет его на ratio, каждый дополнительный запрос расходует единицу.
+	budget struct {
+		mu     sync.Mutex
+		tokens float64
+	}
+)
+
+func (it Retrying) retry() Retry {
+	return it.Retry
+}
+
+func (it Retrying) unwrap() Executor {
+	return it.Executor
+}
+
+// validate проверяет настройки повтора.
+func (it Retry) validate() error {
+	if it.Attempts < 0 || it.Backoff < 0 || it.MaxBackoff < 0 || it.Budget < 0 {
+		return errors.New("invalid retry: must not be negative")
+	}
+	return nil
+}
+
+// delay возвращает паузу перед повтором с номером attempt (с единицы).
+func (it Retry) delay(attempt int) time.Duration {
+	delay := it.Backoff
+	for i := 1; i < attempt && delay > 0; i++ {
+		delay *= 2
+		if it.MaxBackoff > 0 && delay >= it.MaxBackoff {
+			break
+		}
+	}
+	if it.MaxBackoff > 0 && delay > it.MaxBackoff {
+		delay = it.MaxBackoff
+	}
+	return delay
+}
+
+// deposit пополняет бюджет после очередного чтения.
+func (it *budget) deposit(ratio float64) {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	it.tokens = min(it.tokens+ratio, maxBudgetTokens)
+}
+
+// withdraw расходует единицу бюджета, если она есть.
+func (it *budget) withdraw() bool {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	if it.tokens < 1 {
+		return false
+	}
+	it.tokens--
+	return true
+}
+
+// retriable сообщает, стоит ли повторить результаты исполнителя.
+func retriable(exec Executor, results []rueidis.RedisResult) bool {
+	r, ok := as[Retrier](exec)
+	return ok && r.Retriable(results)
+}
+
+// allowRetry проверяет, что повтор укладывается в бюджет.
+func (it *Cobweb) allowRetry(retry Retry) bool {
+	return retry.Budget == 0 || it.retryBudget.withdraw()
+}
+
+// sleep ждёт d или отмены контекста.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,125 @@
+package cobweb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+
+	"github.com/kuroko-shirai/axolotl/v1/internal/node"
+	"github.com/redis/rueidis"
+)
+
+var (
+	ErrScanNodeLost = errors.New("scan node left its group")
+	ErrScanNodeOpen = errors.New("circuit breaker of scan node is open")
+)
+
+type (
+	// Scan курсорная итерация SCAN, HSCAN, SSCAN или ZSCAN. Курсор
+	// действителен только на ноде, которая его выдала, поэтому вся итерация
+	// идёт на одной ноде, выбранной политикой маршрутизации при старте.
+	Scan struct {
+		// Command строит команду для курсора, например
+		//  func(cursor uint64) rueidis.Completed {
+		//      return client.B().Scan().Cursor(cursor).Match("user:*").Build()
+		//  }
+		Command func(cursor uint64) rueidis.Completed
+		// Restarts число перезапусков итерации с начала на другой ноде,
+		// если нода отказала или покинула группу; 0 — сразу ошибка. После
+		// перезапуска уже выданные элементы могут повториться.
+		Restarts int
+	}
+)
+
+// Scan возвращает итератор по элементам курсорной команды. HSCAN и ZSCAN
+// выдают поля и значения (члены и счёт) поочерёдно, как их возвращает
+// Redis. Ошибка выдаётся последним элементом итератора.
+func (it *Cobweb) Scan(ctx context.Context, scan Scan) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		settings := it.settings.Load()
+
+		var exclude []string
+		for restarts := 0; ; restarts++ {
+			address, done, err := it.scanNode(ctx, settings, scan, exclude, yield)
+			if done {
+				if err != nil {
+					yield("", err)
+				}
+				return
+			}
+
+			if restarts >= scan.Restarts {
+				yield("", fmt.Errorf("scan on %s failed: %w", address, err))
+				return
+			}
+
+			settings.logger.Debug("restarting scan", "address", address, "attempt", restarts+1, "error", err)
+			exclude = append(exclude, address)
+		}
+	}
+}
+
+// scanNode проходит итерацию на одной ноде. done сообщает, что итерация
+// завершена, прервана получателем или закончилась ошибкой, которую
+// перезапуск не исправит; иначе err — отказ ноды address.
+func (it *Cobweb) scanNode(ctx context.Context, settings *settings, scan Scan, exclude []string, yield func(string, error) bool) (string, bool, error) {
+	cmd := scan.Command(0)
+	target := it.route(settings, SingleCmd{Cmd: cmd}, exclude)
+	if _, ok := it.node(target); !ok {
+		return "", true, ErrNoTarget
+	}
+	settings.observer.Route(target, ReasonPolicy)
+
+	for {
+		// Нода могла покинуть группу, и её клиент будет закрыт.
+		n, ok := it.group(target.Group).Node(target.Address)
+		if !ok {
+			return target.Address, false, ErrScanNodeLost
+		}
+
+		entry, done, err := it.scanStep(ctx, settings, target, n, cmd)
+		if done || err != nil {
+			return target.Address, done, err
+		}
+
+		for _, element := range entry.Elements {
+			if !yield(element, nil) {
+				return target.Address, true, nil
+			}
+		}
+
+		if entry.Cursor == 0 {
+			return target.Address, true, nil
+		}
+		cmd = scan.Command(entry.Cursor)
+	}
+}
+
+// scanStep выполняет одну команду итерации на ноде.
+func (it *Cobweb) scanStep(ctx context.Context, settings *settings, target Target, n node.Node, cmd rueidis.Completed) (rueidis.ScanEntry, bool, error) {
+	if !it.acquire(settings, target.Address) {
+		return rueidis.ScanEntry{}, false, ErrScanNodeOpen
+	}
+
+	results, err := executeOn(ctx, SingleCmd{Cmd: cmd}, n)
+	if errors.Is(err, rueidis.ErrClosing) {
+		// Нода покинула группу после проверки в scanNode.
+		it.release(settings, target.Address)
+		return rueidis.ScanEntry{}, false, ErrScanNodeLost
+	}
+	it.observe(ctx, settings, target.Address, results)
+	if err != nil {
+		return rueidis.ScanEntry{}, true, err
+	}
+
+	if err := failure(results); err != nil {
+		return rueidis.ScanEntry{}, false, err
+	}
+
+	entry, err := results[0].AsScanEntry()
+	if err != nil {
+		return rueidis.ScanEntry{}, true, err
+	}
+	return entry, false, nil
+}
@@ -0,0 +1,197 @@
+package cobweb
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/redis/rueidis"
+)
+
+const (
+	defaultChunkSize   = 500
+	defaultMaxInFlight = 4
+)
+
+type (
+	// ScatterCmd — для больших пакетов чтения: пакет делится на части по
+	// ChunkSize команд, которые параллельно выполняются на нескольких нодах
+	// группы, выбранной политикой маршрутизации. Ноды для частей
+	// выбираются случайно с весом по свободной ёмкости CPU, результаты
+	// собираются в исходном порядке команд.
+	ScatterCmd struct {
+		Cmds        []rueidis.Completed
+		ChunkSize   int // Команд в части; по умолчанию 500.
+		MaxInFlight int // Частей, выполняемых одновременно; по умолчанию 4.
+		// Retry повтор части на другой ноде; нулевое значение — настройки
+		// обёртки Retrying или Config.Retry.
+		Retry Retry
+	}
+
+	// scatterer исполнитель, который Cobweb выполняет по частям.
+	scatterer interface {
+		scatter() ScatterCmd
+	}
+)
+
+// Execute выполняет весь пакет на одном клиенте; по частям пакет
+// выполняет только Cobweb.Execute.
+func (it ScatterCmd) Execute(ctx context.Context, client rueidis.Client) ([]rueidis.RedisResult, error) {
+	return MultiCmd{Cmds: it.Cmds}.Execute(ctx, client)
+}
+
+func (it ScatterCmd) scatter() ScatterCmd {
+	return it
+}
+
+func (it ScatterCmd) names() []string {
+	return commandNames(it.Cmds)
+}
+
+// scatter выполняет пакет по частям. Ошибка возвращается, если хотя бы
+// для одной части не нашлось ноды; результаты остальных частей при этом
+// сохраняются.
+func (it *Cobweb) scatter(ctx context.Context, settings *settings, span Span, exec Executor, s ScatterCmd) ([]rueidis.RedisResult, error) {
+	for i, cmd := range s.Cmds {
+		if err := readOnly(i, cmd); err != nil {
+			return nil, err
+		}
+	}
+
+	retry := s.Retry
+	if retry == (Retry{}) {
+		retry = settings.retry
+		if r, ok := as[retrying](exec); ok {
+			retry = r.retry()
+		}
+	}
+
+	cmds := s.Cmds
+	if retry.Attempts > 0 {
+		cmds = MultiCmd{Cmds: cmds}.pin().(MultiCmd).Cmds
+	}
+
+	size := s.ChunkSize
+	if size <= 0 {
+		size = defaultChunkSize
+	}
+	inFlight := s.MaxInFlight
+	if inFlight <= 0 {
+		inFlight = defaultMaxInFlight
+	}
+
+	// Группа выбирается политикой один раз на весь пакет.
+	group := it.route(settings, exec, nil).Group
+
+	var (
+		results = make([]rueidis.RedisResult, len(cmds))
+		slots   = make(chan struct{}, inFlight)
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		failed  error
+	)
+	for start := 0; start < len(cmds); start += size {
+		end := min(start+size, len(cmds))
+
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			err := it.chunk(ctx, settings, span, exec, group, retry, cmds[start:end], results[start:end])
+			if err != nil {
+				mu.Lock()
+				if failed == nil {
+					failed = err
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return results, failed
+}
+
+// chunk выполняет часть пакета и записывает её результаты в results.
+func (it *Cobweb) chunk(ctx context.Context, settings *settings, span Span, exec Executor, group Group, retry Retry, cmds []rueidis.Completed, results []rueidis.RedisResult) error {
+	if retry.Budget > 0 {
+		it.retryBudget.deposit(retry.Budget)
+	}
+
+	var exclude []string
+	for attempt := 0; ; {
+		target := it.scatterTarget(settings, exec, group, exclude)
+		n, ok := it.node(target)
+		if !ok || slices.Contains(exclude, target.Address) {
+			if attempt > 0 {
+				// Повторять негде — остаётся последний результат.
+				return nil
+			}
+			return ErrNoTarget
+		}
+
+		if !it.acquire(settings, target.Address) {
+			exclude = append(exclude, target.Address)
+			continue
+		}
+
+		reason := ReasonPolicy
+		if attempt > 0 {
+			reason = ReasonRetry
+		}
+		it.notify(settings, span, target, reason)
+
+		if !n.Hold() {
+			// Нода покинула группу между выбором и отправкой.
+			it.release(settings, target.Address)
+			exclude = append(exclude, target.Address)
+			continue
+		}
+		start := time.Now()
+		chunk := n.Client().DoMulti(ctx, cmds...)
+		n.Release()
+		it.observe(ctx, settings, target.Address, chunk)
+		it.report(settings, exec, target, time.Since(start), chunk, nil)
+		copy(results, chunk)
+
+		if attempt >= retry.Attempts || failure(chunk) == nil || !it.allowRetry(retry) {
+			return nil
+		}
+		attempt++
+
+		exclude = append(exclude, target.Address)
+		if retry.Alternate {
+			group = other(group)
+		}
+
+		if err := sleep(ctx, retry.delay(attempt)); err != nil {
+			return nil
+		}
+	}
+}
+
+// scatterTarget выбирает ноду группы для части пакета с весом по
+// свободной ёмкости CPU.
+func (it *Cobweb) scatterTarget(settings *settings, exec Executor, group Group, exclude []string) Target {
+	eligible := it.eligible(settings, it.layout(settings), exec, exclude)
+
+	var layout Layout
+	if group == GroupMasters {
+		layout.Masters = eligible.Masters
+	} else {
+		layout.Replicas = eligible.Replicas
+	}
+
+	return WeightedRandom{}.Route(it.snapshot(settings), layout)
+}
+
+// other возвращает вторую группу.
+func other(group Group) Group {
+	if group == GroupMasters {
+		return GroupReplicas
+	}
+	return GroupMasters
+}
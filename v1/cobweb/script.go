@@ -0,0 +1,99 @@
+package cobweb
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+
+	"github.com/redis/rueidis"
+)
+
+type (
+	// Script Lua-скрипт только для чтения. Скрипт, который пытается писать,
+	// Redis отклоняет сам.
+	Script struct {
+		source string
+		sha    string
+	}
+
+	// ScriptCmd выполняет скрипт через EVALSHA_RO на ноде, выбранной по
+	// загрузке. Если нода ответила NOSCRIPT, скрипт загружается на неё
+	// через SCRIPT LOAD, и вызов повторяется.
+	ScriptCmd struct {
+		Script *Script
+		Keys   []string
+		Args   []string
+	}
+
+	// FunctionCmd вызывает функцию Redis через FCALL_RO на ноде, выбранной
+	// по загрузке. Библиотека функций должна быть загружена на мастер:
+	// реплики получают её через репликацию.
+	FunctionCmd struct {
+		Function string
+		Keys     []string
+		Args     []string
+	}
+)
+
+func NewScript(source string) *Script {
+	sum := sha1.Sum([]byte(source))
+	return &Script{
+		source: source,
+		sha:    hex.EncodeToString(sum[:]),
+	}
+}
+
+// SHA возвращает SHA1 скрипта.
+func (it *Script) SHA() string {
+	return it.sha
+}
+
+func (it ScriptCmd) Execute(ctx context.Context, client rueidis.Client) ([]rueidis.RedisResult, error) {
+	result := client.Do(ctx, it.command(client))
+
+	if err, ok := rueidis.IsRedisErr(result.Error()); ok && err.IsNoScript() {
+		load := client.Do(ctx, client.B().ScriptLoad().Script(it.Script.source).Build())
+		if load.Error() != nil {
+			return []rueidis.RedisResult{load}, nil
+		}
+		result = client.Do(ctx, it.command(client))
+	}
+
+	return []rueidis.RedisResult{result}, nil
+}
+
+func (it ScriptCmd) command(client rueidis.Client) rueidis.Completed {
+	return client.B().EvalshaRo().
+		Sha1(it.Script.sha).
+		Numkeys(int64(len(it.Keys))).
+		Key(it.Keys...).
+		Arg(it.Args...).
+		Build()
+}
+
+func (it FunctionCmd) Execute(ctx context.Context, client rueidis.Client) ([]rueidis.RedisResult, error) {
+	cmd := client.B().FcallRo().
+		Function(it.Function).
+		Numkeys(int64(len(it.Keys))).
+		Key(it.Keys...).
+		Arg(it.Args...).
+		Build()
+
+	return []rueidis.RedisResult{client.Do(ctx, cmd)}, nil
+}
+
+func (it ScriptCmd) Retriable(results []rueidis.RedisResult) bool {
+	return failure(results) != nil
+}
+
+func (it FunctionCmd) Retriable(results []rueidis.RedisResult) bool {
+	return failure(results) != nil
+}
+
+func (it ScriptCmd) names() []string {
+	return []string{"EVALSHA_RO"}
+}
+
+func (it FunctionCmd) names() []string {
+	return []string{"FCALL_RO"}
+}
@@ -0,0 +1,35 @@
+package cobweb
+
+import (
+	"github.com/kuroko-shirai/axolotl/v1/monitor"
+)
+
+type (
+	// SampleMonitor монитор, который сообщает время и возраст измерений.
+	// Если Monitor его реализует и задан Config.MaxAge, устаревшие
+	// измерения не участвуют в маршрутизации.
+	SampleMonitor interface {
+		Samples() map[string]monitor.Sample
+	}
+)
+
+// snapshot возвращает загрузку нод для политики маршрутизации.
+func (it *Cobweb) snapshot(settings *settings) map[string]float64 {
+	if view := it.freshView(settings); view != nil {
+		return view.Loads
+	}
+
+	sm, ok := settings.monitor.(SampleMonitor)
+	if settings.maxAge <= 0 || !ok {
+		return settings.monitor.Snapshot()
+	}
+
+	samples := sm.Samples()
+	result := make(map[string]float64, len(samples))
+	for addr, sample := range samples {
+		if sample.Value >= 0 && sample.Age <= settings.maxAge {
+			result[addr] = sample.Value
+		}
+	}
+	return result
+}
@@ -31,28 +31,179 @@ type (
 	MultiCacheCmd struct {
 		Cmds []rueidis.CacheableTTL
 	}
+
+	// WriteCmd — для одной команды записи. Всегда выполняется на мастере.
+	WriteCmd struct {
+		Cmd rueidis.Completed
+	}
+
+	// WriteMultiCmd — для DoMulti с командами записи. Всегда выполняется на
+	// мастере.
+	WriteMultiCmd struct {
+		Cmds []rueidis.Completed
+	}
+
+	// writer помечает исполнители, которые маршрутизируются только на мастера.
+	writer interface {
+		Executor
+		commands() []rueidis.Completed
+	}
+
+	// wrapper исполнитель-обёртка, уточняющий маршрутизацию другого
+	// исполнителя.
+	wrapper interface {
+		unwrap() Executor
+	}
+
+	// pinner исполнитель, который умеет закрепить свои команды для
+	// повторной отправки.
+	pinner interface {
+		pin() Executor
+	}
 )
 
 func (it SingleCmd) Execute(ctx context.Context, client rueidis.Client) ([]rueidis.RedisResult, error) {
-	if !it.Cmd.IsReadOnly() {
-		return nil, ErrWriteCommand
+	if err := readOnly(0, it.Cmd); err != nil {
+		return nil, err
 	}
 	return []rueidis.RedisResult{client.Do(ctx, it.Cmd)}, nil
 }
 
 func (it MultiCmd) Execute(ctx context.Context, client rueidis.Client) ([]rueidis.RedisResult, error) {
-	for _, cmd := range it.Cmds {
-		if !cmd.IsReadOnly() {
-			return nil, ErrWriteCommand
+	for i, cmd := range it.Cmds {
+		if err := readOnly(i, cmd); err != nil {
+			return nil, err
 		}
 	}
 	return client.DoMulti(ctx, it.Cmds...), nil
 }
 
 func (it CacheCmd) Execute(ctx context.Context, client rueidis.Client) ([]rueidis.RedisResult, error) {
+	if err := readOnly(0, rueidis.Completed(it.Cmd.Cmd)); err != nil {
+		return nil, err
+	}
 	return []rueidis.RedisResult{client.DoCache(ctx, it.Cmd.Cmd, it.Cmd.TTL)}, nil
 }
 
 func (it MultiCacheCmd) Execute(ctx context.Context, client rueidis.Client) ([]rueidis.RedisResult, error) {
+	for i, cmd := range it.Cmds {
+		if err := readOnly(i, rueidis.Completed(cmd.Cmd)); err != nil {
+			return nil, err
+		}
+	}
 	return client.DoMultiCache(ctx, it.Cmds...), nil
 }
+
+func (it SingleCmd) Retriable(results []rueidis.RedisResult) bool {
+	return failure(results) != nil
+}
+
+func (it MultiCmd) Retriable(results []rueidis.RedisResult) bool {
+	return failure(results) != nil
+}
+
+func (it CacheCmd) Retriable(results []rueidis.RedisResult) bool {
+	return failure(results) != nil
+}
+
+func (it MultiCacheCmd) Retriable(results []rueidis.RedisResult) bool {
+	return failure(results) != nil
+}
+
+func (it WriteCmd) Execute(ctx context.Context, client rueidis.Client) ([]rueidis.RedisResult, error) {
+	return []rueidis.RedisResult{client.Do(ctx, it.Cmd)}, nil
+}
+
+func (it WriteCmd) commands() []rueidis.Completed {
+	return []rueidis.Completed{it.Cmd}
+}
+
+func (it WriteMultiCmd) Execute(ctx context.Context, client rueidis.Client) ([]rueidis.RedisResult, error) {
+	return client.DoMulti(ctx, it.Cmds...), nil
+}
+
+func (it WriteMultiCmd) commands() []rueidis.Completed {
+	return it.Cmds
+}
+
+// readOnly проверяет, что команда с позицией index в пакете только читает.
+func readOnly(index int, cmd rueidis.Completed) error {
+	if cmd.IsReadOnly() {
+		return nil
+	}
+
+	return &WriteCommandError{
+		Index:   index,
+		Command: commandName(cmd),
+	}
+}
+
+// as ищет в цепочке обёрток исполнитель, реализующий T.
+func as[T any](exec Executor) (T, bool) {
+	for exec != nil {
+		if t, ok := exec.(T); ok {
+			return t, true
+		}
+		w, ok := exec.(wrapper)
+		if !ok {
+			break
+		}
+		exec = w.unwrap()
+	}
+
+	var zero T
+	return zero, false
+}
+
+// pin закрепляет команды исполнителя, чтобы rueidis не переиспользовал их
+// после первой отправки: повтор и хедж отправляют те же команды ещё раз.
+// Исполнители, не реализующие pinner, возвращаются как есть.
+func pin(exec Executor) Executor {
+	if p, ok := exec.(pinner); ok {
+		return p.pin()
+	}
+	return exec
+}
+
+func (it SingleCmd) pin() Executor {
+	it.Cmd = it.Cmd.Pin()
+	return it
+}
+
+func (it MultiCmd) pin() Executor {
+	cmds := make([]rueidis.Completed, len(it.Cmds))
+	for i, cmd := range it.Cmds {
+		cmds[i] = cmd.Pin()
+	}
+	it.Cmds = cmds
+	return it
+}
+
+func (it CacheCmd) pin() Executor {
+	it.Cmd.Cmd = it.Cmd.Cmd.Pin()
+	return it
+}
+
+func (it MultiCacheCmd) pin() Executor {
+	cmds := make([]rueidis.CacheableTTL, len(it.Cmds))
+	for i, cmd := range it.Cmds {
+		cmds[i] = rueidis.CT(cmd.Cmd.Pin(), cmd.TTL)
+	}
+	it.Cmds = cmds
+	return it
+}
+
+func (it Stale) pin() Executor {
+	it.Executor = pin(it.Executor)
+	return it
+}
+
+func (it Consistent) pin() Executor {
+	it.Executor = pin(it.Executor)
+	return it
+}
+
+func (it Retrying) pin() Executor {
+	it.Executor = pin(it.Executor)
+	return it
+}
@@ -0,0 +1,183 @@
+package cobweb
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/kuroko-shirai/axolotl/v1/internal/node"
+	"github.com/redis/rueidis"
+)
+
+const (
+	defaultSubscriptionCheck = time.Second
+	// maxSubscriptionBackoff предел паузы перед новым размещением подписки
+	// после ошибок нод подряд.
+	maxSubscriptionBackoff = 30 * time.Second
+)
+
+type (
+	// Subscription подписка Pub/Sub. Redis передаёт PUBLISH на реплики,
+	// поэтому подписка размещается на ноде любой группы по загрузке так же,
+	// как чтение, и переносится на другую ноду, если её нода перегружена,
+	// исключена из маршрутизации или недоступна.
+	Subscription struct {
+		// Command строит команду подписки SUBSCRIBE, PSUBSCRIBE или
+		// SSUBSCRIBE; вызывается при каждом размещении подписки.
+		Command func(b rueidis.Builder) rueidis.Completed
+		Handler func(msg rueidis.PubSubMessage)
+		// Gap вызывается после переноса подписки: сообщения, опубликованные
+		// между Since и Until, могли быть потеряны. Необязателен.
+		Gap func(gap Gap)
+		// Check период проверки загрузки ноды подписки; по умолчанию 1s.
+		Check time.Duration
+	}
+
+	// Gap перерыв подписки при переносе с одной ноды на другую.
+	Gap struct {
+		From  string    // Адрес прежней ноды.
+		To    string    // Адрес новой ноды.
+		Since time.Time // Момент отключения от прежней ноды.
+		Until time.Time // Момент подписки на новой ноде.
+		Err   error     // Ошибка прежней ноды; nil при переносе из-за загрузки.
+	}
+)
+
+// Subscribe держит подписку до отмены ctx или отписки. Возвращает nil после
+// отписки, ошибку контекста после его отмены и ошибку команды подписки,
+// если нода её отклонила.
+func (it *Cobweb) Subscribe(ctx context.Context, sub Subscription) error {
+	check := sub.Check
+	if check <= 0 {
+		check = defaultSubscriptionCheck
+	}
+
+	// Пауза после ошибки ноды начинается с check и удваивается с каждой
+	// следующей ошибкой подряд.
+	backoff := Retry{Backoff: check, MaxBackoff: max(check, maxSubscriptionBackoff)}
+
+	var (
+		previous *Gap
+		exclude  []string
+		failures int
+	)
+	for {
+		settings := it.settings.Load()
+		target := it.route(settings, SingleCmd{}, exclude)
+		n, ok := it.node(target)
+		if !ok {
+			// Подходящей ноды нет — ждём и пробуем снова, включая
+			// исключённые ноды.
+			exclude = nil
+			if err := sleep(ctx, check); err != nil {
+				return err
+			}
+			continue
+		}
+
+		reason := ReasonPolicy
+		if previous != nil {
+			reason = ReasonRebalance
+			if sub.Gap != nil {
+				previous.To, previous.Until = target.Address, time.Now()
+				sub.Gap(*previous)
+			}
+		}
+		settings.observer.Route(target, reason)
+
+		moved, err := it.receive(ctx, settings, sub, target, n, check)
+		if !moved {
+			return err
+		}
+
+		settings.logger.Info("moving subscription", "address", target.Address, "error", err)
+		previous = &Gap{From: target.Address, Since: time.Now(), Err: err}
+		exclude = []string{target.Address}
+		if err == nil || errors.Is(err, rueidis.ErrClosing) {
+			// Перенос из-за загрузки или ухода ноды из группы — сразу.
+			failures = 0
+			continue
+		}
+
+		// Нода отказала: соседняя может отказать так же, поэтому подписка
+		// размещается заново не сразу, а после паузы.
+		failures++
+		if err := sleep(ctx, backoff.delay(failures)); err != nil {
+			return err
+		}
+	}
+}
+
+// receive держит подписку на ноде. moved сообщает, что подписку нужно
+// перенести на другую ноду; err — ошибка ноды или причина завершения.
+func (it *Cobweb) receive(ctx context.Context, settings *settings, sub Subscription, target Target, n node.Node, check time.Duration) (bool, error) {
+	subCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan error, 1)
+	if !n.Hold() {
+		return true, rueidis.ErrClosing
+	}
+	go func() {
+		defer n.Release()
+		client := n.Client()
+		done <- client.Receive(subCtx, sub.Command(client.B()), sub.Handler)
+	}()
+
+	ticker := time.NewTicker(check)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case err := <-done:
+			if ctx.Err() != nil {
+				return false, ctx.Err()
+			}
+			if err == nil {
+				return false, nil
+			}
+			if _, ok := rueidis.IsRedisErr(err); ok {
+				// Нода отклонила команду подписки: перенос не поможет.
+				return false, err
+			}
+			if hm, ok := settings.monitor.(HealthMonitor); ok {
+				hm.Report(target.Address, err)
+			}
+			return true, err
+		case <-ticker.C:
+			if it.misplaced(settings, target) {
+				cancel()
+				<-done
+				return true, nil
+			}
+		case <-ctx.Done():
+			<-done
+			return false, ctx.Err()
+		}
+	}
+}
+
+// misplaced сообщает, что подписку стоит перенести: нода покинула группу,
+// исключена из маршрутизации или перегружена при наличии менее
+// загруженной ноды.
+func (it *Cobweb) misplaced(settings *settings, target Target) bool {
+	if _, ok := it.group(target.Group).Node(target.Address); !ok {
+		return true
+	}
+
+	layout := it.eligible(settings, it.layout(settings), SingleCmd{}, nil)
+	if !slices.Contains(layout.group(target.Group).Addresses, target.Address) {
+		return true
+	}
+
+	snapshot := it.snapshot(settings)
+	load, ok := snapshot[target.Address]
+	if !ok || load <= layout.group(target.Group).Threshold {
+		return false
+	}
+
+	alternative := it.route(settings, SingleCmd{}, []string{target.Address})
+	other, ok := snapshot[alternative.Address]
+	return ok && other < load
+}
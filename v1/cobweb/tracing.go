@@ -0,0 +1,90 @@
+package cobweb
+
+import (
+	"context"
+
+	"github.com/redis/rueidis"
+)
+
+type (
+	// Call описывает вызов Execute для трассировки.
+	Call struct {
+		Executor string   // Имя стратегии, например SingleCmd.
+		Commands []string // Имена команд; пусто для собственных исполнителей.
+		Medians  Medians  // Медианы загрузки групп на момент вызова.
+	}
+
+	// Tracer начинает span на каждый вызов Execute.
+	Tracer interface {
+		Start(ctx context.Context, call Call) (context.Context, Span)
+	}
+
+	// Span трассирует один вызов Execute. Route вызывается на каждую
+	// выбранную ноду, включая повторы и хеджи; End — один раз в конце.
+	Span interface {
+		Route(target Target, reason Reason)
+		End(err error)
+	}
+
+	// named исполнитель, который сообщает имена своих команд.
+	named interface {
+		names() []string
+	}
+
+	// nopSpan span по умолчанию.
+	nopSpan struct{}
+)
+
+func (nopSpan) Route(Target, Reason) {}
+func (nopSpan) End(error)            {}
+
+// notify сообщает наблюдателю и span о выборе ноды.
+func (it *Cobweb) notify(settings *settings, span Span, target Target, reason Reason) {
+	settings.observer.Route(target, reason)
+	span.Route(target, reason)
+}
+
+func (it SingleCmd) names() []string {
+	return []string{commandName(it.Cmd)}
+}
+
+func (it MultiCmd) names() []string {
+	return commandNames(it.Cmds)
+}
+
+func (it CacheCmd) names() []string {
+	return []string{commandName(rueidis.Completed(it.Cmd.Cmd))}
+}
+
+func (it MultiCacheCmd) names() []string {
+	names := make([]string, len(it.Cmds))
+	for i, cmd := range it.Cmds {
+		names[i] = commandName(rueidis.Completed(cmd.Cmd))
+	}
+	return names
+}
+
+func (it WriteCmd) names() []string {
+	return commandNames(it.commands())
+}
+
+func (it WriteMultiCmd) names() []string {
+	return commandNames(it.commands())
+}
+
+// commandName возвращает имя команды.
+func commandName(cmd rueidis.Completed) string {
+	if commands := cmd.Commands(); len(commands) > 0 {
+		return commands[0]
+	}
+	return ""
+}
+
+// commandNames возвращает имена команд пакета.
+func commandNames(cmds []rueidis.Completed) []string {
+	names := make([]string, len(cmds))
+	for i, cmd := range cmds {
+		names[i] = commandName(cmd)
+	}
+	return names
+}
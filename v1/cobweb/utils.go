@@ -1,6 +1,7 @@
 package cobweb
 
 import (
+	"math/rand/v2"
 	"sort"
 )
 
@@ -21,3 +22,125 @@ func median(values []float64) float64 {
 
 	return sorted[n/2]
 }
+
+// groupMedian возвращает медиану загрузки CPU нод группы, по которым есть
+// данные мониторинга.
+func groupMedian(snapshot map[string]float64, addresses []string) float64 {
+	cpus := make([]float64, 0, len(addresses))
+	for _, addr := range addresses {
+		if cpu, ok := snapshot[addr]; ok {
+			cpus = append(cpus, cpu)
+		}
+	}
+
+	return median(cpus)
+}
+
+// minLoad возвращает минимальную загрузку нод с данными мониторинга.
+func minLoad(snapshot map[string]float64, addresses []string) (float64, bool) {
+	least, found := 0.0, false
+	for _, addr := range addresses {
+		cpu, ok := snapshot[addr]
+		if !ok {
+			continue
+		}
+		if !found || cpu < least {
+			least, found = cpu, true
+		}
+	}
+	return least, found
+}
+
+// spread выбирает ноду группы для чтения. Ноды выше порога группы и ноды,
+// загруженные больше наименее загруженной на hotMargin, отбрасываются, а
+// среди оставшихся нода выбирается случайно с весом по свободной ёмкости
+// CPU. Так чтения распределяются по свободным нодам, а не сходятся до
+// следующего опроса на одной. Ноды без данных мониторинга выбираются, только
+// если данных нет ни по одной ноде.
+func spread(snapshot map[string]float64, addresses []string, threshold, least float64, found bool) string {
+	if len(addresses) == 0 {
+		return ""
+	}
+	if !found {
+		return addresses[rand.IntN(len(addresses))]
+	}
+
+	limit := least + hotMargin
+	if threshold > 0 && least <= threshold && threshold < limit {
+		limit = threshold
+	}
+
+	total := 0.0
+	for _, addr := range addresses {
+		if cool(snapshot, addr, limit) {
+			total += freeCapacity(snapshot, addr)
+		}
+	}
+
+	point, last := rand.Float64()*total, ""
+	for _, addr := range addresses {
+		if !cool(snapshot, addr, limit) {
+			continue
+		}
+		last = addr
+		point -= freeCapacity(snapshot, addr)
+		if point < 0 {
+			return addr
+		}
+	}
+	return last
+}
+
+// cool сообщает, что загрузка ноды известна и не выше limit.
+func cool(snapshot map[string]float64, address string, limit float64) bool {
+	cpu, ok := snapshot[address]
+	return ok && cpu <= limit
+}
+
+// freeCapacity возвращает свободную ёмкость CPU ноды. Ноде без данных
+// мониторинга и полностью загруженной ноде остаётся минимальный вес, чтобы
+// она не выпадала из выбора окончательно.
+func freeCapacity(snapshot map[string]float64, address string) float64 {
+	const minWeight = 1.0
+
+	cpu, ok := snapshot[address]
+	if !ok || fullLoad-cpu < minWeight {
+		return minWeight
+	}
+	return fullLoad - cpu
+}
+
+// overloaded определяет перегрузку группы с гистерезисом: свободная группа
+// становится перегруженной выше Threshold, а перегруженная освобождается
+// только на ExitThreshold и ниже.
+func overloaded(was bool, median float64, group GroupLayout) bool {
+	if !was {
+		return median > group.Threshold
+	}
+
+	exit := group.ExitThreshold
+	if exit <= 0 || exit > group.Threshold {
+		exit = group.Threshold
+	}
+	return median > exit
+}
+
+// filter возвращает адреса, для которых keep истинно. Если подходят все
+// адреса, возвращается исходный срез без копирования.
+func filter(addresses []string, keep func(string) bool) []string {
+	for i, address := range addresses {
+		if keep(address) {
+			continue
+		}
+
+		result := make([]string, i, len(addresses))
+		copy(result, addresses[:i])
+		for _, address := range addresses[i+1:] {
+			if keep(address) {
+				result = append(result, address)
+			}
+		}
+		return result
+	}
+	return addresses
+}
@@ -0,0 +1,142 @@
+package cobweb
+
+import (
+	"time"
+
+	"github.com/kuroko-shirai/axolotl/v1/monitor"
+)
+
+type (
+	// Viewer монитор, который публикует неизменяемый снимок состояния нод.
+	// Если Monitor его реализует, маршрутизация читает снимок без
+	// копирования, а медианы групп и наименее загруженные ноды считаются
+	// один раз на снимок, а не на каждое чтение. Health и Replication
+	// снимка используются вместо HealthMonitor и ReplicationMonitor.
+	Viewer interface {
+		View() *monitor.View
+	}
+
+	// GroupStats предрасчитанные агрегаты группы по снимку загрузки.
+	GroupStats struct {
+		Median float64 // Медиана загрузки нод группы с данными.
+		Least  string  // Наименее загруженная нода с данными; пусто, если данных нет.
+	}
+
+	// fresh снимок монитора без устаревших измерений.
+	fresh struct {
+		source *monitor.View // Снимок монитора, из которого построен view.
+		maxAge time.Duration
+		view   *monitor.View
+		until  time.Time // Момент, после которого устареет ещё одно измерение view.
+	}
+
+	// routing агрегаты групп, посчитанные для одного снимка и одного
+	// состава групп.
+	routing struct {
+		view     *monitor.View
+		masters  []string
+		replicas []string
+		stats    [2]GroupStats // По Group.
+	}
+)
+
+// latest возвращает последний снимок монитора, если монитор его публикует.
+func latest(settings *settings) *monitor.View {
+	viewer, ok := settings.monitor.(Viewer)
+	if !ok {
+		return nil
+	}
+	return viewer.View()
+}
+
+// freshView возвращает снимок, загрузку из которого можно использовать как
+// есть: без измерений старше MaxAge. Если устарели измерения только части
+// нод, возвращается снимок без них; он строится один раз и используется,
+// пока не устареет ещё одно измерение или монитор не опубликует новый
+// снимок. nil означает, что снимка нет или он не сообщает время измерений.
+func (it *Cobweb) freshView(settings *settings) *monitor.View {
+	view := latest(settings)
+	if view == nil || settings.maxAge <= 0 {
+		return view
+	}
+
+	now := time.Now()
+	if now.Sub(view.Oldest) <= settings.maxAge {
+		return view
+	}
+	if view.Times == nil {
+		return nil
+	}
+
+	f := it.fresh.Load()
+	// Снимок без измерений остаётся пустым до следующей публикации.
+	if f != nil && f.source == view && f.maxAge == settings.maxAge && (len(f.view.Loads) == 0 || !now.After(f.until)) {
+		return f.view
+	}
+
+	f = &fresh{
+		source: view,
+		maxAge: settings.maxAge,
+		view:   view.Since(now.Add(-settings.maxAge)),
+	}
+	f.until = f.view.Oldest.Add(settings.maxAge)
+	it.fresh.Store(f)
+
+	return f.view
+}
+
+// withStats дополняет раскладку агрегатами групп, посчитанными по всем
+// нодам групп.
+func (it *Cobweb) withStats(view *monitor.View, full, layout Layout) Layout {
+	r := it.routing.Load()
+	if r == nil || r.view != view || !same(r.masters, full.Masters.Addresses) || !same(r.replicas, full.Replicas.Addresses) {
+		r = newRouting(view, full)
+		it.routing.Store(r)
+	}
+
+	layout.Masters.Stats = &r.stats[GroupMasters]
+	layout.Replicas.Stats = &r.stats[GroupReplicas]
+	return layout
+}
+
+// newRouting считает агрегаты групп по снимку.
+func newRouting(view *monitor.View, layout Layout) *routing {
+	r := &routing{
+		view:     view,
+		masters:  layout.Masters.Addresses,
+		replicas: layout.Replicas.Addresses,
+	}
+	r.stats[GroupMasters] = newGroupStats(view, layout.Masters.Addresses)
+	r.stats[GroupReplicas] = newGroupStats(view, layout.Replicas.Addresses)
+	return r
+}
+
+// newGroupStats считает агрегаты группы по упорядоченным нодам снимка.
+func newGroupStats(view *monitor.View, addresses []string) GroupStats {
+	members := make(map[string]bool, len(addresses))
+	for _, address := range addresses {
+		members[address] = true
+	}
+
+	var (
+		stats GroupStats
+		loads = make([]float64, 0, len(addresses))
+	)
+	for _, address := range view.Ordered {
+		if !members[address] {
+			continue
+		}
+		if stats.Least == "" {
+			stats.Least = address
+		}
+		loads = append(loads, view.Loads[address])
+	}
+	stats.Median = median(loads)
+
+	return stats
+}
+
+// same сообщает, что срезы совпадают: это один и тот же срез адресов.
+func same(a, b []string) bool {
+	return len(a) == len(b) && (len(a) == 0 || &a[0] == &b[0])
+}
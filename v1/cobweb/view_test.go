@@ -0,0 +1,224 @@
+package cobweb
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/kuroko-shirai/axolotl/v1/cluster"
+	"github.com/kuroko-shirai/axolotl/v1/internal/node"
+	"github.com/kuroko-shirai/axolotl/v1/monitor"
+	"github.com/redis/rueidis"
+)
+
+type (
+	// fakeCluster группа с неизменным составом. Клиентов у нод нет, поэтому
+	// выполнять на них можно только исполнители, которые не обращаются к
+	// клиенту.
+	fakeCluster struct {
+		addresses []string
+		nodes     map[string]node.Node
+	}
+
+	// fakeViewer монитор, публикующий заранее собранный View.
+	fakeViewer struct {
+		view *monitor.View
+	}
+)
+
+func (it *fakeCluster) Nodes() []node.Node               { return nil }
+func (it *fakeCluster) Node(a string) (node.Node, bool)  { n, ok := it.nodes[a]; return n, ok }
+func (it *fakeCluster) Addresses() []string              { return it.addresses }
+func (it *fakeCluster) Sync([]string) error              { return nil }
+func (it *fakeCluster) Close()                           {}
+func (it *fakeViewer) Snapshot() map[string]float64      { return it.view.Loads }
+func (it *fakeViewer) View() *monitor.View               { return it.view }
+func (it *fakeViewer) Health() map[string]monitor.Health { return it.view.Health }
+func (it *fakeViewer) Report(string, error)              {}
+func (it *fakeViewer) Replication() map[string]monitor.Replication {
+	return it.view.Replication
+}
+func (it *fakeCluster) Prepare([]string) (func(), func(), error) {
+	return func() {}, func() {}, nil
+}
+
+// nopExec исполнитель, который не обращается к клиенту и не выделяет
+// память: на нём виден только путь маршрутизации Execute.
+type nopExec struct{}
+
+func (nopExec) Execute(context.Context, rueidis.Client) ([]rueidis.RedisResult, error) {
+	return nil, nil
+}
+
+// newFakeCluster создаёт группу из нод без клиентов.
+func newFakeCluster(addresses []string) *fakeCluster {
+	nodes := make(map[string]node.Node, len(addresses))
+	for _, address := range addresses {
+		nodes[address] = node.FromClient(address, nil)
+	}
+	return &fakeCluster{addresses: addresses, nodes: nodes}
+}
+
+// newView собирает View так же, как монитор: с упорядоченными нодами и
+// состоянием всех нод.
+func newView(masters, replicas map[string]float64) *monitor.View {
+	view := &monitor.View{
+		Time:        time.Now(),
+		Oldest:      time.Now(),
+		Loads:       make(map[string]float64),
+		Times:       make(map[string]time.Time),
+		Health:      make(map[string]monitor.Health),
+		Replication: make(map[string]monitor.Replication),
+	}
+	for address, load := range masters {
+		view.Loads[address] = load
+		view.Health[address] = monitor.Healthy
+		view.Replication[address] = monitor.Replication{Role: monitor.RoleMaster, LinkUp: true}
+	}
+	for address, load := range replicas {
+		view.Loads[address] = load
+		view.Health[address] = monitor.Healthy
+		view.Replication[address] = monitor.Replication{Role: monitor.RoleReplica, LinkUp: true, LastIO: time.Second}
+	}
+	for address := range view.Loads {
+		view.Times[address] = view.Oldest
+		view.Ordered = append(view.Ordered, address)
+	}
+	slices.SortFunc(view.Ordered, func(a, b string) int {
+		return cmp.Or(cmp.Compare(view.Loads[a], view.Loads[b]), cmp.Compare(a, b))
+	})
+	return view
+}
+
+// newRoutingCobweb создаёт cobweb без подключений к нодам: маршрутизация
+// читает только состав групп и View монитора.
+func newRoutingCobweb(tb testing.TB, policy Policy) *Cobweb {
+	tb.Helper()
+
+	view := newView(
+		map[string]float64{"10.0.0.1:6379": 30},
+		map[string]float64{"10.0.0.2:6379": 20, "10.0.0.3:6379": 5, "10.0.0.4:6379": 45},
+	)
+	config := (&Config{
+		Masters:  &cluster.Config{Addresses: []string{"10.0.0.1:6379"}, MaxThreshold: 50},
+		Replicas: &cluster.Config{Addresses: []string{"10.0.0.2:6379", "10.0.0.3:6379", "10.0.0.4:6379"}, MaxThreshold: 40},
+		Monitor:  &fakeViewer{view: view},
+		MaxLag:   Lag{Time: 5 * time.Second},
+	}).normalize()
+
+	cobweb := &Cobweb{
+		masters:  newFakeCluster(config.Masters.Addresses),
+		replicas: newFakeCluster(config.Replicas.Addresses),
+	}
+	cobweb.settings.Store(newSettings(config, policy))
+	return cobweb
+}
+
+func TestRouteWithView(t *testing.T) {
+	cobweb := newRoutingCobweb(t, &ThresholdMedian{})
+	settings := cobweb.settings.Load()
+
+	target := cobweb.route(settings, SingleCmd{}, nil)
+	if target.Group != GroupReplicas || target.Address != "10.0.0.3:6379" {
+		t.Fatalf("route = %+v, want least loaded replica", target)
+	}
+
+	// Исключённая для вызова нода не выбирается, но медиана реплик и
+	// состояние политики по-прежнему считаются по всей группе.
+	target = cobweb.route(settings, SingleCmd{}, []string{"10.0.0.3:6379"})
+	if target.Address != "10.0.0.2:6379" {
+		t.Fatalf("route with exclude = %+v, want next least loaded replica", target)
+	}
+}
+
+func TestRouteStaleNode(t *testing.T) {
+	cobweb := newRoutingCobweb(t, &ThresholdMedian{})
+	settings := *cobweb.settings.Load()
+	settings.maxAge = 5 * time.Second
+
+	// Устарело только измерение наименее загруженной реплики: она выпадает
+	// из загрузки, остальной снимок используется как есть.
+	view := settings.monitor.(*fakeViewer).view
+	view.Oldest = time.Now().Add(-time.Minute)
+	view.Times["10.0.0.3:6379"] = view.Oldest
+
+	target := cobweb.route(&settings, SingleCmd{}, nil)
+	if target.Address != "10.0.0.2:6379" {
+		t.Fatalf("route = %+v, want least loaded replica with a fresh sample", target)
+	}
+
+	var exec Executor = SingleCmd{}
+	allocs := testing.AllocsPerRun(1000, func() {
+		cobweb.route(&settings, exec, nil)
+	})
+	if allocs != 0 {
+		t.Fatalf("route with a stale node allocates %v times per call, want 0", allocs)
+	}
+}
+
+// policies встроенные политики маршрутизации.
+var policies = []struct {
+	name   string
+	policy func() Policy
+}{
+	{name: "ThresholdMedian", policy: func() Policy { return &ThresholdMedian{} }},
+	{name: "AlwaysReplica", policy: func() Policy { return AlwaysReplica{} }},
+	{name: "LeastLoaded", policy: func() Policy { return LeastLoaded{} }},
+	{name: "PowerOfTwo", policy: func() Policy { return PowerOfTwo{} }},
+	{name: "WeightedRandom", policy: func() Policy { return WeightedRandom{} }},
+}
+
+func TestRouteAllocs(t *testing.T) {
+	for _, p := range policies {
+		t.Run(p.name, func(t *testing.T) {
+			cobweb := newRoutingCobweb(t, p.policy())
+			settings := cobweb.settings.Load()
+			var exec Executor = SingleCmd{}
+
+			allocs := testing.AllocsPerRun(1000, func() {
+				cobweb.route(settings, exec, nil)
+			})
+			if allocs != 0 {
+				t.Fatalf("route allocates %v times per call, want 0", allocs)
+			}
+		})
+	}
+}
+
+func TestExecuteAllocs(t *testing.T) {
+	for _, p := range policies {
+		t.Run(p.name, func(t *testing.T) {
+			cobweb := newRoutingCobweb(t, p.policy())
+			ctx := context.Background()
+			var exec Executor = nopExec{}
+
+			allocs := testing.AllocsPerRun(1000, func() {
+				if _, err := cobweb.Execute(ctx, exec); err != nil {
+					t.Fatal(err)
+				}
+			})
+			if allocs != 0 {
+				t.Fatalf("Execute allocates %v times per call, want 0", allocs)
+			}
+		})
+	}
+}
+
+func BenchmarkRoute(b *testing.B) {
+	for _, p := range policies {
+		b.Run(p.name, func(b *testing.B) {
+			cobweb := newRoutingCobweb(b, p.policy())
+			settings := cobweb.settings.Load()
+			var exec Executor = SingleCmd{}
+
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					cobweb.route(settings, exec, nil)
+				}
+			})
+		})
+	}
+}
@@ -2,6 +2,7 @@ package node
 
 import (
 	"errors"
+	"sync"
 
 	"github.com/redis/rueidis"
 )
@@ -10,6 +11,15 @@ type (
 	Node struct {
 		client  rueidis.Client
 		address string
+		users   *users
+	}
+
+	// users учёт удержаний клиента ноды, общий для копий Node.
+	users struct {
+		mu      sync.Mutex
+		held    int
+		retired bool
+		closed  bool
 	}
 
 	Config struct {
@@ -37,10 +47,16 @@ func New(config *Config) (Node, error) {
 		return Node{}, err
 	}
 
+	return FromClient(config.Address, client), nil
+}
+
+// FromClient создаёт ноду поверх уже открытого клиента.
+func FromClient(address string, client rueidis.Client) Node {
 	return Node{
-		address: config.Address,
+		address: address,
 		client:  client,
-	}, nil
+		users:   &users{},
+	}
 }
 
 func (it Node) Address() string {
@@ -50,3 +66,61 @@ func (it Node) Address() string {
 func (it Node) Client() rueidis.Client {
 	return it.client
 }
+
+// Close закрывает клиент сразу, не дожидаясь удержаний.
+func (it Node) Close() {
+	it.users.mu.Lock()
+	it.users.closed = true
+	it.users.mu.Unlock()
+
+	it.client.Close()
+}
+
+// Hold удерживает клиент открытым до Release, даже если нода тем временем
+// выведена через Retire. Возвращает false, если клиент уже закрыт.
+func (it Node) Hold() bool {
+	if it.users == nil {
+		return false
+	}
+
+	it.users.mu.Lock()
+	defer it.users.mu.Unlock()
+
+	if it.users.closed {
+		return false
+	}
+	it.users.held++
+	return true
+}
+
+// Release отпускает удержание. Клиент выведенной ноды закрывается вместе с
+// последним удержанием.
+func (it Node) Release() {
+	it.users.mu.Lock()
+	it.users.held--
+	closing := it.users.retired && it.users.held == 0 && !it.users.closed
+	if closing {
+		it.users.closed = true
+	}
+	it.users.mu.Unlock()
+
+	if closing {
+		it.client.Close()
+	}
+}
+
+// Retire выводит ноду: клиент закрывается, как только будут отпущены все
+// удержания, или сразу, если их нет.
+func (it Node) Retire() {
+	it.users.mu.Lock()
+	it.users.retired = true
+	closing := it.users.held == 0 && !it.users.closed
+	if closing {
+		it.users.closed = true
+	}
+	it.users.mu.Unlock()
+
+	if closing {
+		it.client.Close()
+	}
+}
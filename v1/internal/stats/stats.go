@@ -0,0 +1,149 @@
+package stats
+
+import (
+	"math"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/kuroko-shirai/axolotl/v1/internal/cpu"
+)
+
+type (
+	// Stats сигналы загрузки ноды, извлечённые из INFO.
+	Stats struct {
+		CPU                   cpu.CPUStats
+		ConnectedClients      int64
+		BlockedClients        int64
+		MaxClients            int64
+		OpsPerSec             int64
+		UsedMemory            int64
+		MaxMemory             int64
+		MemFragmentationRatio float64
+		RejectedConnections   int64
+		RunID                 string // run_id: идентификатор процесса redis.
+		Replication           Replication
+	}
+
+	// Replication состояние репликации ноды из секции replication.
+	Replication struct {
+		Role             string // "master" или "slave".
+		ReplID           string // master_replid: идентификатор истории репликации.
+		MasterHost       string
+		MasterPort       string
+		MasterLinkUp     bool
+		MasterLastIO     int64 // master_last_io_seconds_ago; -1, если неизвестно.
+		MasterReplOffset int64
+		SlaveReplOffset  int64
+		Replicas         []string // Адреса подключённых реплик мастера (host:port).
+	}
+)
+
+func New(info string) (Stats, error) {
+	cpu, err := cpu.New(info)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	fields := parse(info)
+
+	return Stats{
+		CPU:                   cpu,
+		ConnectedClients:      integer(fields, "connected_clients"),
+		BlockedClients:        integer(fields, "blocked_clients"),
+		MaxClients:            integer(fields, "maxclients"),
+		OpsPerSec:             integer(fields, "instantaneous_ops_per_sec"),
+		UsedMemory:            integer(fields, "used_memory"),
+		MaxMemory:             integer(fields, "maxmemory"),
+		MemFragmentationRatio: float(fields, "mem_fragmentation_ratio"),
+		RejectedConnections:   integer(fields, "rejected_connections"),
+		RunID:                 fields["run_id"],
+		Replication:           replication(fields),
+	}, nil
+}
+
+// NewReplication разбирает только секцию replication, например ответ
+// INFO replication.
+func NewReplication(info string) Replication {
+	return replication(parse(info))
+}
+
+func replication(fields map[string]string) Replication {
+	return Replication{
+		Role:             fields["role"],
+		ReplID:           fields["master_replid"],
+		MasterHost:       fields["master_host"],
+		MasterPort:       fields["master_port"],
+		MasterLinkUp:     fields["master_link_status"] == "up",
+		MasterLastIO:     signed(fields, "master_last_io_seconds_ago"),
+		MasterReplOffset: integer(fields, "master_repl_offset"),
+		SlaveReplOffset:  integer(fields, "slave_repl_offset"),
+		Replicas:         replicas(fields),
+	}
+}
+
+// replicas извлекает адреса реплик из строк вида
+// "slave0:ip=10.0.0.3,port=6379,state=online,offset=100,lag=0".
+func replicas(fields map[string]string) []string {
+	count := integer(fields, "connected_slaves")
+	addresses := make([]string, 0, count)
+	for i := int64(0); i < count; i++ {
+		var ip, port string
+		for _, pair := range strings.Split(fields["slave"+strconv.FormatInt(i, 10)], ",") {
+			key, val, ok := strings.Cut(pair, "=")
+			if !ok {
+				continue
+			}
+			switch key {
+			case "ip":
+				ip = val
+			case "port":
+				port = val
+			}
+		}
+		if ip != "" && port != "" {
+			addresses = append(addresses, net.JoinHostPort(ip, port))
+		}
+	}
+	return addresses
+}
+
+// parse разбирает ответ INFO в пары "ключ: значение".
+func parse(info string) map[string]string {
+	fields := make(map[string]string)
+	for _, line := range strings.Split(info, "\n") {
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		fields[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return fields
+}
+
+// integer возвращает неотрицательное целое значение поля или 0.
+func integer(fields map[string]string, key string) int64 {
+	val, err := strconv.ParseInt(fields[key], 10, 64)
+	if err != nil || val < 0 {
+		return 0
+	}
+	return val
+}
+
+// signed возвращает целое значение поля или -1, если его нет.
+func signed(fields map[string]string, key string) int64 {
+	val, err := strconv.ParseInt(fields[key], 10, 64)
+	if err != nil {
+		return -1
+	}
+	return val
+}
+
+// float возвращает конечное неотрицательное значение поля или 0.
+func float(fields map[string]string, key string) float64 {
+	val, err := strconv.ParseFloat(fields[key], 64)
+	if err != nil || math.IsNaN(val) || math.IsInf(val, 0) || val < 0 {
+		return 0
+	}
+	return val
+}
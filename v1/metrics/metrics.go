@@ -0,0 +1,303 @@
+// Package metrics экспортирует состояние монитора и маршрутизации cobweb в
+// текстовом формате Prometheus.
+package metrics
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kuroko-shirai/axolotl/v1/cobweb"
+	"github.com/redis/rueidis"
+)
+
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+const (
+	OutcomeOK         = "ok"          // Исполнитель завершился без ошибок.
+	OutcomeRedisError = "redis_error" // Нода ответила ошибкой Redis.
+	OutcomeRejected   = "rejected"    // Команда записи отклонена исполнителем чтения.
+	OutcomeError      = "error"       // Сетевая ошибка, отмена или истечение времени.
+)
+
+// buckets границы гистограмм длительности в секундах.
+var buckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5}
+
+// escaper экранирует значения меток.
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+type (
+	// Snapshotter источник загрузки нод, например *monitor.Monitor.
+	Snapshotter interface {
+		Snapshot() map[string]float64
+	}
+
+	// Medianer источник медиан загрузки групп, например *cobweb.Cobweb.
+	Medianer interface {
+		Medians() cobweb.Medians
+	}
+
+	// Config источники значений, которые снимаются при каждом запросе
+	// метрик. Оба поля необязательны. Cobweb принимает Metrics как
+	// наблюдателя при создании, поэтому его обычно задают позже через
+	// SetConfig.
+	Config struct {
+		Monitor Snapshotter
+		Cobweb  Medianer
+	}
+
+	// Metrics собирает события монитора и cobweb и отдаёт их по HTTP.
+	// Реализует monitor.Observer и cobweb.Observer.
+	Metrics struct {
+		config Config
+
+		mu           sync.Mutex
+		routes       map[routeKey]uint64
+		results      map[resultKey]uint64
+		durations    map[string]*histogram // Имя стратегии -> длительность.
+		polls        map[string]*histogram // Адрес ноды -> длительность INFO.
+		pollFailures map[string]uint64
+	}
+
+	routeKey struct {
+		group   string
+		address string
+		reason  string
+	}
+
+	resultKey struct {
+		executor string
+		group    string
+		address  string
+		outcome  string
+	}
+
+	histogram struct {
+		counts []uint64 // Число наблюдений по границам buckets, не накопительное.
+		sum    float64
+		count  uint64
+	}
+
+	// writer пишет метрики и запоминает первую ошибку записи.
+	writer struct {
+		w   *bufio.Writer
+		err error
+	}
+)
+
+func New(config Config) *Metrics {
+	return &Metrics{
+		config:       config,
+		routes:       make(map[routeKey]uint64),
+		results:      make(map[resultKey]uint64),
+		durations:    make(map[string]*histogram),
+		polls:        make(map[string]*histogram),
+		pollFailures: make(map[string]uint64),
+	}
+}
+
+// SetConfig заменяет источники значений.
+func (it *Metrics) SetConfig(config Config) {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	it.config = config
+}
+
+// Route учитывает выбор ноды.
+func (it *Metrics) Route(target cobweb.Target, reason cobweb.Reason) {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	it.routes[routeKey{
+		group:   target.Group.String(),
+		address: target.Address,
+		reason:  string(reason),
+	}]++
+}
+
+// Result учитывает итог выполнения исполнителя.
+func (it *Metrics) Result(result cobweb.Result) {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	it.results[resultKey{
+		executor: result.Executor,
+		group:    result.Target.Group.String(),
+		address:  result.Target.Address,
+		outcome:  outcome(result.Err),
+	}]++
+	observe(it.durations, result.Executor, result.Duration)
+}
+
+// Poll учитывает опрос INFO ноды.
+func (it *Metrics) Poll(address string, latency time.Duration, err error) {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	observe(it.polls, address, latency)
+	if err != nil {
+		it.pollFailures[address]++
+	}
+}
+
+// ServeHTTP отдаёт метрики в текстовом формате Prometheus.
+func (it *Metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", contentType)
+	if err := it.WriteText(w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// WriteText пишет метрики в текстовом формате Prometheus.
+func (it *Metrics) WriteText(w io.Writer) error {
+	out := &writer{w: bufio.NewWriter(w)}
+
+	it.mu.Lock()
+	config := it.config
+	it.mu.Unlock()
+
+	if config.Monitor != nil {
+		snapshot := config.Monitor.Snapshot()
+		out.header("axolotl_node_load", "gauge", "Current load score of a node reported by the monitor.")
+		for _, address := range sortedKeys(snapshot) {
+			out.sample("axolotl_node_load", labels("address", address), snapshot[address])
+		}
+	}
+
+	if config.Cobweb != nil {
+		medians := config.Cobweb.Medians()
+		out.header("axolotl_group_median_load", "gauge", "Median load score of a node group.")
+		out.sample("axolotl_group_median_load", labels("group", cobweb.GroupMasters.String()), medians.Masters)
+		out.sample("axolotl_group_median_load", labels("group", cobweb.GroupReplicas.String()), medians.Replicas)
+	}
+
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	out.header("axolotl_routes_total", "counter", "Nodes chosen by cobweb by group, address and reason.")
+	for _, key := range sortedKeys(it.routes) {
+		out.sample("axolotl_routes_total",
+			labels("group", key.group, "address", key.address, "reason", key.reason),
+			float64(it.routes[key]))
+	}
+
+	out.header("axolotl_executions_total", "counter", "Executor results by strategy, node and outcome.")
+	for _, key := range sortedKeys(it.results) {
+		out.sample("axolotl_executions_total",
+			labels("executor", key.executor, "group", key.group, "address", key.address, "outcome", key.outcome),
+			float64(it.results[key]))
+	}
+
+	out.header("axolotl_execution_duration_seconds", "histogram", "Executor duration on a node.")
+	for _, executor := range sortedKeys(it.durations) {
+		out.histogram("axolotl_execution_duration_seconds", "executor", executor, it.durations[executor])
+	}
+
+	out.header("axolotl_poll_duration_seconds", "histogram", "INFO poll duration of a node.")
+	for _, address := range sortedKeys(it.polls) {
+		out.histogram("axolotl_poll_duration_seconds", "address", address, it.polls[address])
+	}
+
+	out.header("axolotl_poll_failures_total", "counter", "Failed INFO polls of a node.")
+	for _, address := range sortedKeys(it.pollFailures) {
+		out.sample("axolotl_poll_failures_total", labels("address", address), float64(it.pollFailures[address]))
+	}
+
+	if out.err != nil {
+		return out.err
+	}
+	return out.w.Flush()
+}
+
+// outcome классифицирует ошибку выполнения.
+func outcome(err error) string {
+	if err == nil {
+		return OutcomeOK
+	}
+	if errors.Is(err, cobweb.ErrWriteCommand) {
+		return OutcomeRejected
+	}
+	if _, ok := rueidis.IsRedisErr(err); ok {
+		return OutcomeRedisError
+	}
+	return OutcomeError
+}
+
+// observe добавляет наблюдение в гистограмму по ключу.
+func observe(histograms map[string]*histogram, key string, d time.Duration) {
+	h, ok := histograms[key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(buckets))}
+		histograms[key] = h
+	}
+
+	seconds := d.Seconds()
+	if i, _ := slices.BinarySearch(buckets, seconds); i < len(buckets) {
+		h.counts[i]++
+	}
+	h.sum += seconds
+	h.count++
+}
+
+func (it *writer) printf(format string, args ...any) {
+	if it.err != nil {
+		return
+	}
+	_, it.err = fmt.Fprintf(it.w, format, args...)
+}
+
+func (it *writer) header(name, kind, help string) {
+	it.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func (it *writer) sample(name, labels string, value float64) {
+	it.printf("%s%s %s\n", name, labels, strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+func (it *writer) histogram(name, label, value string, h *histogram) {
+	var cumulative uint64
+	for i, bound := range buckets {
+		cumulative += h.counts[i]
+		it.sample(name+"_bucket", labels(label, value, "le", strconv.FormatFloat(bound, 'g', -1, 64)), float64(cumulative))
+	}
+	it.sample(name+"_bucket", labels(label, value, "le", "+Inf"), float64(h.count))
+	it.sample(name+"_sum", labels(label, value), h.sum)
+	it.sample(name+"_count", labels(label, value), float64(h.count))
+}
+
+// labels форматирует пары имя-значение как набор меток.
+func labels(pairs ...string) string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(pairs[i])
+		b.WriteString(`="`)
+		b.WriteString(escaper.Replace(pairs[i+1]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// sortedKeys возвращает ключи карты в детерминированном порядке.
+func sortedKeys[K comparable, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b K) int {
+		return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+	})
+	return keys
+}
@@ -0,0 +1,123 @@
+package metrics_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kuroko-shirai/axolotl/v1/cobweb"
+	"github.com/kuroko-shirai/axolotl/v1/metrics"
+)
+
+type (
+	snapshot map[string]float64
+
+	medians cobweb.Medians
+)
+
+func (it snapshot) Snapshot() map[string]float64 {
+	return it
+}
+
+func (it medians) Medians() cobweb.Medians {
+	return cobweb.Medians(it)
+}
+
+func TestServeHTTP(t *testing.T) {
+	m := metrics.New(metrics.Config{
+		Monitor: snapshot{"10.0.0.1:6379": 12.5},
+		Cobweb:  medians{Masters: 40, Replicas: 12.5},
+	})
+
+	replica := cobweb.Target{Group: cobweb.GroupReplicas, Address: "10.0.0.2:6379"}
+	m.Route(replica, cobweb.ReasonPolicy)
+	m.Route(replica, cobweb.ReasonPolicy)
+	m.Route(replica, cobweb.ReasonRetry)
+	m.Result(cobweb.Result{Target: replica, Executor: "SingleCmd", Duration: 3 * time.Millisecond})
+	m.Result(cobweb.Result{Target: replica, Executor: "SingleCmd", Duration: 30 * time.Millisecond})
+	m.Result(cobweb.Result{Target: replica, Executor: "SingleCmd", Duration: 5 * time.Second, Err: errors.New("i/o timeout")})
+	m.Poll("10.0.0.1:6379", 700*time.Microsecond, nil)
+	m.Poll("10.0.0.1:6379", 2*time.Second, errors.New("i/o timeout"))
+
+	server := httptest.NewServer(m)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := string(body)
+
+	for _, want := range []string{
+		"# TYPE axolotl_node_load gauge",
+		`axolotl_node_load{address="10.0.0.1:6379"} 12.5`,
+		`axolotl_group_median_load{group="masters"} 40`,
+		`axolotl_group_median_load{group="replicas"} 12.5`,
+
+		"# TYPE axolotl_routes_total counter",
+		`axolotl_routes_total{group="replicas",address="10.0.0.2:6379",reason="policy"} 2`,
+		`axolotl_routes_total{group="replicas",address="10.0.0.2:6379",reason="retry"} 1`,
+
+		`axolotl_executions_total{executor="SingleCmd",group="replicas",address="10.0.0.2:6379",outcome="ok"} 2`,
+		`axolotl_executions_total{executor="SingleCmd",group="replicas",address="10.0.0.2:6379",outcome="error"} 1`,
+
+		// Корзины гистограммы накопительные, +Inf равна числу наблюдений.
+		"# TYPE axolotl_execution_duration_seconds histogram",
+		`axolotl_execution_duration_seconds_bucket{executor="SingleCmd",le="0.0025"} 0`,
+		`axolotl_execution_duration_seconds_bucket{executor="SingleCmd",le="0.005"} 1`,
+		`axolotl_execution_duration_seconds_bucket{executor="SingleCmd",le="0.025"} 1`,
+		`axolotl_execution_duration_seconds_bucket{executor="SingleCmd",le="0.05"} 2`,
+		`axolotl_execution_duration_seconds_bucket{executor="SingleCmd",le="2.5"} 2`,
+		`axolotl_execution_duration_seconds_bucket{executor="SingleCmd",le="+Inf"} 3`,
+		`axolotl_execution_duration_seconds_sum{executor="SingleCmd"} 5.033`,
+		`axolotl_execution_duration_seconds_count{executor="SingleCmd"} 3`,
+
+		`axolotl_poll_duration_seconds_bucket{address="10.0.0.1:6379",le="0.0005"} 0`,
+		`axolotl_poll_duration_seconds_bucket{address="10.0.0.1:6379",le="0.001"} 1`,
+		`axolotl_poll_duration_seconds_bucket{address="10.0.0.1:6379",le="2.5"} 2`,
+		`axolotl_poll_duration_seconds_bucket{address="10.0.0.1:6379",le="+Inf"} 2`,
+		`axolotl_poll_duration_seconds_count{address="10.0.0.1:6379"} 2`,
+		`axolotl_poll_failures_total{address="10.0.0.1:6379"} 1`,
+	} {
+		if !strings.Contains(text, want+"\n") {
+			t.Errorf("missing line %q in:\n%s", want, text)
+		}
+	}
+}
+
+func TestOutcome(t *testing.T) {
+	m := metrics.New(metrics.Config{})
+	target := cobweb.Target{Group: cobweb.GroupMasters, Address: "10.0.0.1:6379"}
+	m.Result(cobweb.Result{
+		Target:   target,
+		Executor: "SingleCmd",
+		Err:      &cobweb.WriteCommandError{Command: "SET"},
+	})
+
+	var b strings.Builder
+	if err := m.WriteText(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `axolotl_executions_total{executor="SingleCmd",group="masters",address="10.0.0.1:6379",outcome="rejected"} 1`
+	if !strings.Contains(b.String(), want+"\n") {
+		t.Errorf("missing line %q in:\n%s", want, b.String())
+	}
+}
@@ -0,0 +1,231 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/kuroko-shirai/axolotl/v1/cluster"
+)
+
+// Topology возвращает раскладку нод по ролям по последнему успешному опросу
+// каждой ноды. Ноды в состоянии Down в раскладку не входят.
+func (it *Monitor) Topology() cluster.Topology {
+	it.mu.RLock()
+	defer it.mu.RUnlock()
+	return it.topology
+}
+
+// Watch регистрирует обработчик изменений топологии. Обработчик вызывается
+// из цикла опроса после того, как раскладка нод по ролям изменилась.
+func (it *Monitor) Watch(fn func(cluster.Topology)) {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	it.watchers = append(it.watchers, fn)
+}
+
+// topologyOf собирает раскладку нод по ролям в порядке их добавления. Для
+// нод с неудачным последним опросом берётся последняя известная роль, а
+// исключаются только ноды в состоянии Down, чтобы единичная ошибка опроса
+// не меняла группы cobweb в обход порогов Failures.
+func topologyOf(nodes []node, stats map[string]info, failures Failures) cluster.Topology {
+	var topology cluster.Topology
+	for _, n := range nodes {
+		stat := stats[n.address]
+		if stat.health(failures) == Down {
+			continue
+		}
+		switch stat.replication.Role {
+		case RoleMaster:
+			topology.Masters = append(topology.Masters, n.address)
+		case RoleReplica:
+			topology.Replicas = append(topology.Replicas, n.address)
+		}
+	}
+	return topology
+}
+
+// notify пересобирает топологию и оповещает обработчики, если она изменилась.
+func (it *Monitor) notify() {
+	it.mu.Lock()
+	topology := topologyOf(it.nodes, it.stats, it.config.Failures)
+	if slices.Equal(topology.Masters, it.topology.Masters) && slices.Equal(topology.Replicas, it.topology.Replicas) {
+		it.mu.Unlock()
+		return
+	}
+	it.topology = topology
+	watchers := it.watchers
+	it.mu.Unlock()
+
+	for _, fn := range watchers {
+		fn(topology)
+	}
+}
+
+// SetTopology приводит список отслеживаемых нод к раскладке: подключает
+// новые ноды и отключает исключённые. История нод, которые остаются в
+// раскладке, сохраняется. Ноды раскладки становятся затравочными адресами.
+func (it *Monitor) SetTopology(topology cluster.Topology) error {
+	addresses := make([]string, 0, len(topology.Masters)+len(topology.Replicas))
+	addresses = append(addresses, topology.Masters...)
+	addresses = append(addresses, topology.Replicas...)
+
+	seeds := make(map[string]bool, len(addresses))
+	for _, address := range addresses {
+		seeds[address] = true
+	}
+
+	it.mu.Lock()
+	it.seeds = seeds
+	config := it.config
+	it.mu.Unlock()
+
+	err := it.sync(config, addresses, nil)
+	it.publish()
+	it.notify()
+
+	return err
+}
+
+// discover подключает ноды, о которых сообщают мастера (список реплик) и
+// реплики (адрес мастера), и отключает ноды, о которых больше никто не
+// сообщает. Ноды с неудачным последним опросом сообщают о соседях по
+// последним известным данным, пока не перейдут в Down, поэтому единичная
+// ошибка опроса не отключает их соседей. Затравочные адреса не отключаются
+// никогда.
+func (it *Monitor) discover() {
+	it.mu.RLock()
+	addresses := make([]string, 0, len(it.nodes))
+	for _, n := range it.nodes {
+		if it.seeds[n.address] {
+			addresses = append(addresses, n.address)
+		}
+	}
+	var referenced []string
+	for _, stat := range it.stats {
+		if stat.health(it.config.Failures) == Down {
+			continue
+		}
+		if stat.replication.Master != "" {
+			referenced = append(referenced, stat.replication.Master)
+		}
+		referenced = append(referenced, stat.replication.Replicas...)
+	}
+	config := it.config
+	it.mu.RUnlock()
+
+	slices.Sort(referenced)
+	if err := it.sync(config, append(addresses, referenced...), nil); err != nil {
+		it.logger().Warn("failed to add discovered nodes", "error", err)
+	}
+}
+
+// sync подключает ноды из addresses, которых ещё нет, и отключает ноды, не
+// входящие в addresses. Новые ноды добавляются в порядке addresses. Адрес,
+// за которым оказалась уже отслеживаемая нода (тот же run_id), запоминается
+// как её другой адрес и второй раз не подключается. Подключения открываются
+// с учётными данными config.
+//
+// Если commit равен nil, ошибка подключения к одной ноде не мешает
+// остальным. Иначе при любой ошибке подключения новые клиенты закрываются и
+// список нод не меняется, а при успехе commit вызывается под той же
+// блокировкой, под которой заменяется список нод.
+func (it *Monitor) sync(config Config, addresses []string, commit func()) error {
+	it.syncMu.Lock()
+	defer it.syncMu.Unlock()
+
+	it.mu.RLock()
+	known := make(map[string]bool, len(it.nodes))
+	runIDs := make(map[string]string, len(it.nodes))
+	for _, n := range it.nodes {
+		known[n.address] = true
+		if runID := it.stats[n.address].runID; runID != "" {
+			runIDs[runID] = n.address
+		}
+	}
+	aliases := maps.Clone(it.aliases)
+	it.mu.RUnlock()
+
+	var (
+		errs  []error
+		added []node
+		stats = make(map[string]info)
+	)
+	want := make(map[string]bool, len(addresses))
+	for _, address := range addresses {
+		if canonical, ok := aliases[address]; ok {
+			address = canonical
+		}
+		want[address] = true
+		if known[address] {
+			continue
+		}
+		known[address] = true
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		n, stat, err := connect(ctx, config, address)
+		cancel()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if canonical, ok := runIDs[stat.runID]; ok {
+			n.client.Close()
+			aliases[address] = canonical
+			want[canonical] = true
+			it.logger().Debug("node alias found", "address", address, "node", canonical)
+			continue
+		}
+		if stat.runID != "" {
+			runIDs[stat.runID] = address
+		}
+
+		added = append(added, n)
+		stats[address] = stat
+	}
+
+	if commit != nil && len(errs) > 0 {
+		for _, n := range added {
+			n.client.Close()
+		}
+		return errors.Join(errs...)
+	}
+
+	it.mu.Lock()
+	var removed []node
+	nodes := make([]node, 0, len(it.nodes)+len(added))
+	for _, n := range it.nodes {
+		if want[n.address] {
+			nodes = append(nodes, n)
+			continue
+		}
+		removed = append(removed, n)
+		delete(it.stats, n.address)
+	}
+	nodes = append(nodes, added...)
+	maps.Copy(it.stats, stats)
+	it.nodes = nodes
+	for alias, canonical := range aliases {
+		if _, ok := it.stats[canonical]; !ok {
+			delete(aliases, alias)
+		}
+	}
+	it.aliases = aliases
+	if commit != nil {
+		commit()
+	}
+	it.mu.Unlock()
+
+	for _, n := range added {
+		it.logger().Info("node added", "address", n.address)
+	}
+	for _, n := range removed {
+		n.client.Close()
+		it.logger().Info("node removed", "address", n.address)
+	}
+
+	return errors.Join(errs...)
+}
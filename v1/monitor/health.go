@@ -0,0 +1,144 @@
+package monitor
+
+import (
+	"errors"
+)
+
+const (
+	Healthy Health = iota // Нода отвечает.
+	Suspect               // Последние обращения к ноде завершились ошибкой.
+	Down                  // Нода не отвечает и исключается из маршрутизации.
+)
+
+const (
+	defaultSuspectAfter = 1
+	defaultDownAfter    = 3
+)
+
+type (
+	// Health состояние здоровья ноды.
+	Health uint8
+
+	// Failures число подряд идущих ошибок, после которого нода переходит в
+	// состояние Suspect или Down. Учитываются ошибки опроса INFO и ошибки
+	// команд, о которых сообщает Report. Нулевые поля — 1 и 3 соответственно.
+	// Успешный опрос INFO переводит ноду, ушедшую в Down из-за ошибок
+	// команд, обратно в Suspect.
+	Failures struct {
+		Suspect int
+		Down    int
+	}
+)
+
+func (it Health) String() string {
+	switch it {
+	case Healthy:
+		return "healthy"
+	case Suspect:
+		return "suspect"
+	case Down:
+		return "down"
+	default:
+		return "unknown"
+	}
+}
+
+// validate проверяет пороги ошибок.
+func (it Failures) validate() error {
+	if it.Suspect < 0 || it.Down < 0 {
+		return errors.New("invalid failures: must not be negative")
+	}
+	if it.Suspect > 0 && it.Down > 0 && it.Suspect > it.Down {
+		return errors.New("invalid failures: suspect threshold above down threshold")
+	}
+	return nil
+}
+
+// down возвращает число ошибок, после которого нода переходит в Down.
+func (it Failures) down() int {
+	if it.Down == 0 {
+		return defaultDownAfter
+	}
+	return it.Down
+}
+
+// health возвращает состояние ноды по числу подряд идущих ошибок.
+func (it Failures) health(failures int) Health {
+	suspect, down := it.Suspect, it.down()
+	if suspect == 0 {
+		suspect = defaultSuspectAfter
+	}
+
+	switch {
+	case failures >= down:
+		return Down
+	case failures >= suspect:
+		return Suspect
+	default:
+		return Healthy
+	}
+}
+
+// recovered возвращает серию ошибок команд после успешного опроса INFO.
+// Cobweb не читает с ноды в Down, поэтому успешная команда, которая сбросила
+// бы серию, на неё не придёт. Раз нода отвечает на опрос, серия
+// укорачивается до порога Down: нода снова получает чтения, а первая же
+// ошибка команды возвращает её в Down.
+func (it Failures) recovered(failures int) int {
+	return min(failures, it.down()-1)
+}
+
+// health возвращает состояние ноды по худшей из двух серий ошибок.
+func (it info) health(failures Failures) Health {
+	return failures.health(max(it.infoFailures, it.commandFailures))
+}
+
+// Health возвращает состояние здоровья всех нод. Учитывается худшая из двух
+// серий ошибок: опроса INFO и команд, о которых сообщает Report.
+func (it *Monitor) Health() map[string]Health {
+	it.mu.RLock()
+	defer it.mu.RUnlock()
+
+	result := make(map[string]Health, len(it.stats))
+	for addr, stat := range it.stats {
+		result[addr] = stat.health(it.config.Failures)
+	}
+	return result
+}
+
+// Report учитывает результат команды, выполненной на ноде: ошибка
+// продлевает серию ошибок, успех её сбрасывает. Если состояние здоровья
+// ноды изменилось, публикуется новый View.
+func (it *Monitor) Report(address string, err error) {
+	if err == nil {
+		// Успех без серии ошибок ничего не меняет — обходимся без
+		// блокировки на запись.
+		it.mu.RLock()
+		stat, ok := it.stats[address]
+		it.mu.RUnlock()
+		if !ok || stat.commandFailures == 0 {
+			return
+		}
+	}
+
+	it.mu.Lock()
+	stat, ok := it.stats[address]
+	if !ok {
+		it.mu.Unlock()
+		return
+	}
+
+	before := it.config.Failures.health(max(stat.infoFailures, stat.commandFailures))
+	if err != nil {
+		stat.commandFailures++
+	} else {
+		stat.commandFailures = 0
+	}
+	it.stats[address] = stat
+	after := it.config.Failures.health(max(stat.infoFailures, stat.commandFailures))
+	it.mu.Unlock()
+
+	if before != after {
+		it.publish()
+	}
+}
@@ -0,0 +1,110 @@
+package monitor
+
+import (
+	"errors"
+	"time"
+
+	"github.com/kuroko-shirai/axolotl/v1/internal/stats"
+)
+
+const (
+	defaultOpsCapacity   = 100000.0
+	defaultLatencyBudget = 10 * time.Millisecond
+)
+
+type (
+	// Weights веса сигналов в композитной оценке загрузки ноды.
+	Weights struct {
+		CPU           float64 // Загрузка CPU, %.
+		Clients       float64 // Доля занятых подключений: connected_clients / maxclients.
+		Blocked       float64 // Доля заблокированных клиентов: blocked_clients / connected_clients.
+		Ops           float64 // instantaneous_ops_per_sec относительно OpsCapacity.
+		Memory        float64 // Заполненность памяти: used_memory / maxmemory.
+		Fragmentation float64 // Превышение mem_fragmentation_ratio над единицей.
+		Rejected      float64 // Отказы в подключении (rejected_connections) за период опроса.
+		Latency       float64 // Время ответа на INFO относительно LatencyBudget.
+	}
+
+	// Load содержит настройки композитной оценки загрузки ноды. Каждый сигнал
+	// приводится к шкале 0..100, оценка — их взвешенное среднее, поэтому она
+	// сравнима с порогами групп. Нулевое значение — только загрузка CPU.
+	Load struct {
+		Weights       Weights
+		OpsCapacity   float64       // Число ops/sec, соответствующее 100%; по умолчанию 100000.
+		LatencyBudget time.Duration // Время ответа, соответствующее 100%; по умолчанию 10ms.
+	}
+
+	// signals сигналы ноды за один период опроса.
+	signals struct {
+		cpu      float64
+		stats    stats.Stats
+		rejected int64 // Прирост rejected_connections за период.
+		latency  time.Duration
+	}
+)
+
+// validate проверяет настройки композитной оценки.
+func (it Load) validate() error {
+	w := it.Weights
+	for _, weight := range []float64{w.CPU, w.Clients, w.Blocked, w.Ops, w.Memory, w.Fragmentation, w.Rejected, w.Latency} {
+		if weight < 0 {
+			return errors.New("invalid load weights: weight must not be negative")
+		}
+	}
+	if it.OpsCapacity < 0 || it.LatencyBudget < 0 {
+		return errors.New("invalid load scales: must not be negative")
+	}
+	return nil
+}
+
+// score возвращает композитную оценку загрузки ноды.
+func (it Load) score(s signals) float64 {
+	w := it.Weights
+	total := w.CPU + w.Clients + w.Blocked + w.Ops + w.Memory + w.Fragmentation + w.Rejected + w.Latency
+	if total == 0 {
+		return s.cpu
+	}
+
+	opsCapacity := it.OpsCapacity
+	if opsCapacity == 0 {
+		opsCapacity = defaultOpsCapacity
+	}
+	latencyBudget := it.LatencyBudget
+	if latencyBudget == 0 {
+		latencyBudget = defaultLatencyBudget
+	}
+
+	var rejected float64
+	if s.rejected > 0 {
+		rejected = 100
+	}
+
+	sum := w.CPU*s.cpu +
+		w.Clients*ratio(float64(s.stats.ConnectedClients), float64(s.stats.MaxClients)) +
+		w.Blocked*ratio(float64(s.stats.BlockedClients), float64(s.stats.ConnectedClients)) +
+		w.Ops*ratio(float64(s.stats.OpsPerSec), opsCapacity) +
+		w.Memory*ratio(float64(s.stats.UsedMemory), float64(s.stats.MaxMemory)) +
+		w.Fragmentation*fragmentation(s.stats.MemFragmentationRatio) +
+		w.Rejected*rejected +
+		w.Latency*ratio(float64(s.latency), float64(latencyBudget))
+
+	return sum / total
+}
+
+// ratio возвращает отношение value к limit в процентах, не больше 100.
+// Неизвестный (нулевой) предел даёт 0.
+func ratio(value, limit float64) float64 {
+	if limit <= 0 {
+		return 0
+	}
+	return min(value/limit*100, 100)
+}
+
+// fragmentation переводит mem_fragmentation_ratio в проценты: 1.0 — 0%,
+// 2.0 и выше — 100%.
+func fragmentation(ratio float64) float64 {
+	if ratio <= 1 {
+		return 0
+	}
+	return min((ratio-1)*100, 100)
+}
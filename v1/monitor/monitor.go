@@ -3,12 +3,14 @@ package monitor
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/kuroko-shirai/axolotl/v1/internal/cpu"
+	"github.com/kuroko-shirai/axolotl/v1/cluster"
+	"github.com/kuroko-shirai/axolotl/v1/internal/stats"
 	"github.com/redis/rueidis"
 )
 
@@ -20,13 +22,32 @@ type (
 		Password  string        // Пароль redis.
 		Username  string        // Пользователь redis.
 		Ping      time.Duration // Период запуска сбора состояния CPU master- и replica-нод сети.
+		Smoothing Smoothing     // Сглаживание загрузки; по умолчанию без сглаживания.
+		Load      Load          // Композитная оценка загрузки; по умолчанию только CPU.
+		// Discovery включает обнаружение топологии: Addresses служат
+		// затравочными адресами, а остальные ноды находятся через INFO
+		// replication и отслеживаются по мере добавления, удаления и
+		// переключения ролей.
+		Discovery bool
+		Failures  Failures     // Пороги ошибок для состояний здоровья нод.
+		Observer  Observer     // Наблюдатель опросов, например метрики.
+		Logger    *slog.Logger // Журнал монитора; по умолчанию slog.Default().
 	}
 
 	info struct {
-		lastTs time.Time
-		user   float64
-		sys    float64
-		cpu    float64
+		lastTs      time.Time
+		user        float64
+		sys         float64
+		rejected    int64
+		runID       string
+		replication Replication
+		raw         float64 // Мгновенная оценка загрузки за последний период.
+		cpu         float64 // Сглаженная оценка загрузки.
+		smoother    smoother
+		err         error // Ошибка последнего опроса.
+
+		infoFailures    int // Число подряд неудачных опросов INFO.
+		commandFailures int // Число подряд неудачных команд по данным Report.
 	}
 
 	node struct {
@@ -35,101 +56,229 @@ type (
 	}
 
 	Monitor struct {
-		nodes []node
-		mu    sync.RWMutex
-		stats map[string]info
-		ping  time.Duration
+		nodes  []node
+		mu     sync.RWMutex
+		syncMu sync.Mutex // Сериализует изменение списка нод.
+		view   atomic.Pointer[View]
+		// publishMu сериализует публикацию View.
+		publishMu sync.Mutex
+		stats     map[string]info
+		config    Config
+		seeds     map[string]bool
+		// aliases другие адреса отслеживаемых нод, например IP, которые
+		// сообщают реплики вместо имени хоста из конфигурации.
+		aliases  map[string]string
+		topology cluster.Topology
+		watchers []func(cluster.Topology)
 	}
 )
 
-func New(config Config) (Monitor, error) {
+func New(config Config) (*Monitor, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	stats := make(map[string]info, len(config.Addresses))
+	infos := make(map[string]info, len(config.Addresses))
 	nodes := make([]node, 0, len(config.Addresses))
+	seeds := make(map[string]bool, len(config.Addresses))
 	for _, address := range config.Addresses {
-		client, err := rueidis.NewClient(rueidis.ClientOption{
-			Username:       config.Username,
-			Password:       config.Password,
-			InitAddress:    []string{address},
-			SendToReplicas: func(cmd rueidis.Completed) bool { return cmd.IsReadOnly() },
-			Standalone: rueidis.StandaloneOption{
-				ReplicaAddress: []string{address},
-			},
-		})
+		n, stat, err := connect(ctx, config, address)
 		if err != nil {
 			for _, n := range nodes {
 				n.client.Close()
 			}
-			return Monitor{}, fmt.Errorf("failed to connect to %s: %w", address, err)
+			return nil, err
 		}
 
-		infoResp := client.Do(ctx, client.B().Info().Build())
-		if err := infoResp.Error(); err != nil {
-			for _, n := range nodes {
-				n.client.Close()
-			}
-			return Monitor{}, fmt.Errorf("failed to get INFO from %s: %w", address, err)
-		}
+		nodes = append(nodes, n)
+		infos[address] = stat
+		seeds[address] = true
+	}
 
-		infoStr, err := infoResp.ToString()
-		if err != nil {
-			client.Close()
-			for _, n := range nodes {
-				n.client.Close()
-			}
-			return Monitor{}, fmt.Errorf("failed to parse INFO from %s: %w", address, err)
-		}
+	monitor := &Monitor{
+		nodes:    nodes,
+		stats:    infos,
+		config:   config,
+		seeds:    seeds,
+		aliases:  make(map[string]string),
+		topology: topologyOf(nodes, infos, config.Failures),
+	}
+	monitor.publish()
 
-		cpu, err := cpu.New(infoStr)
-		if err != nil {
-			client.Close()
-			for _, n := range nodes {
-				n.client.Close()
-			}
-			return Monitor{}, fmt.Errorf("failed to extract CPU from INFO of %s: %w", address, err)
-		}
+	return monitor, nil
+}
 
-		nodes = append(nodes, node{
-			client:  client,
-			address: address,
-		})
+// connect подключается к ноде и снимает начальную статистику.
+func connect(ctx context.Context, config Config, address string) (node, info, error) {
+	client, err := rueidis.NewClient(rueidis.ClientOption{
+		Username:       config.Username,
+		Password:       config.Password,
+		InitAddress:    []string{address},
+		SendToReplicas: func(cmd rueidis.Completed) bool { return cmd.IsReadOnly() },
+		Standalone: rueidis.StandaloneOption{
+			ReplicaAddress: []string{address},
+		},
+	})
+	if err != nil {
+		return node{}, info{}, fmt.Errorf("failed to connect to %s: %w", address, err)
+	}
 
-		stats[address] = info{
-			user:   cpu.User,
-			sys:    cpu.Sys,
-			cpu:    -1,
-			lastTs: time.Now(),
-		}
+	infoResp := client.Do(ctx, client.B().Info().Build())
+	if err := infoResp.Error(); err != nil {
+		client.Close()
+		return node{}, info{}, fmt.Errorf("failed to get INFO from %s: %w", address, err)
+	}
+
+	infoStr, err := infoResp.ToString()
+	if err != nil {
+		client.Close()
+		return node{}, info{}, fmt.Errorf("failed to parse INFO from %s: %w", address, err)
 	}
 
-	if config.Ping == 0 {
-		return Monitor{}, fmt.Errorf("invalid zero-value ping period")
+	stats, err := stats.New(infoStr)
+	if err != nil {
+		client.Close()
+		return node{}, info{}, fmt.Errorf("failed to extract CPU from INFO of %s: %w", address, err)
+	}
+
+	n := node{
+		client:  client,
+		address: address,
 	}
 
-	return Monitor{
-		nodes: nodes,
-		stats: stats,
-		ping:  config.Ping,
+	return n, info{
+		user:        stats.CPU.User,
+		sys:         stats.CPU.Sys,
+		rejected:    stats.RejectedConnections,
+		runID:       stats.RunID,
+		replication: newReplication(stats.Replication),
+		raw:         -1,
+		cpu:         -1,
+		lastTs:      time.Now(),
+		smoother:    config.Smoothing.newSmoother(),
 	}, nil
 }
 
 func (it *Monitor) Close() {
+	it.mu.RLock()
+	defer it.mu.RUnlock()
+
 	for _, n := range it.nodes {
 		n.client.Close()
 	}
 }
 
+// Update на лету применяет новую конфигурацию: подключает новые ноды,
+// отключает исключённые и заменяет период опроса, сглаживание и веса оценки
+// загрузки. Ноды, которые остаются, сохраняют историю счётчиков CPU; при
+// смене сглаживания его состояние начинается заново с последнего значения.
+// Учётные данные применяются к новым подключениям. Если без Discovery к
+// какой-либо новой ноде подключиться не удалось, Update возвращает ошибку и
+// не меняет ни список нод, ни конфигурацию.
+func (it *Monitor) Update(config Config) error {
+	if err := config.validate(); err != nil {
+		return err
+	}
+
+	seeds := make(map[string]bool, len(config.Addresses))
+	for _, address := range config.Addresses {
+		seeds[address] = true
+	}
+
+	// apply заменяет конфигурацию; вызывается под it.mu.
+	apply := func() {
+		if config.Smoothing != it.config.Smoothing {
+			for address, stat := range it.stats {
+				stat.smoother = config.Smoothing.newSmoother()
+				if stat.raw >= 0 {
+					stat.cpu = stat.smoother.add(stat.raw, stat.lastTs)
+				}
+				it.stats[address] = stat
+			}
+		}
+		it.config = config
+		it.seeds = seeds
+	}
+
+	// Без обнаружения список нод и конфигурация меняются вместе: если хотя
+	// бы одна новая нода недоступна, не меняется ни то, ни другое.
+	if config.Discovery {
+		it.mu.Lock()
+		apply()
+		it.mu.Unlock()
+		it.discover()
+	} else if err := it.sync(config, config.Addresses, apply); err != nil {
+		return err
+	}
+	it.publish()
+	it.notify()
+
+	return nil
+}
+
+// validate проверяет конфигурацию монитора.
+func (it Config) validate() error {
+	if err := it.Smoothing.validate(); err != nil {
+		return err
+	}
+
+	if err := it.Load.validate(); err != nil {
+		return err
+	}
+
+	if err := it.Failures.validate(); err != nil {
+		return err
+	}
+
+	if it.Ping <= 0 {
+		return fmt.Errorf("invalid zero-value ping period")
+	}
+
+	return nil
+}
+
+// period возвращает текущий период опроса.
+func (it *Monitor) period() time.Duration {
+	it.mu.RLock()
+	defer it.mu.RUnlock()
+	return it.config.Ping
+}
+
+// logger возвращает текущий журнал.
+func (it *Monitor) logger() *slog.Logger {
+	it.mu.RLock()
+	defer it.mu.RUnlock()
+
+	if it.config.Logger == nil {
+		return slog.Default()
+	}
+	return it.config.Logger
+}
+
+// discovery сообщает, включено ли обнаружение топологии.
+func (it *Monitor) discovery() bool {
+	it.mu.RLock()
+	defer it.mu.RUnlock()
+	return it.config.Discovery
+}
+
 func (it *Monitor) Run(ctx context.Context) {
-	ticker := time.NewTicker(it.ping)
+	ping := it.period()
+	ticker := time.NewTicker(ping)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
 			it.updateAndLogCPU()
+			if current := it.period(); current != ping {
+				ping = current
+				ticker.Reset(ping)
+			}
 		case <-ctx.Done():
-			log.Println("Monitor stopped")
+			it.logger().Info("monitor stopped")
 			return
 		}
 	}
@@ -137,15 +286,24 @@ func (it *Monitor) Run(ctx context.Context) {
 
 // updateAndLogCPU обновляет статистику CPU и выводит её в лог.
 func (it *Monitor) updateAndLogCPU() {
+	logger := it.logger()
 	if err := it.updateCPUStats(); err != nil {
-		log.Printf("Partial failure during CPU update: %v", err)
+		logger.Warn("failed to update load of all nodes", "error", err)
+	}
+
+	if it.discovery() {
+		it.discover()
 	}
+	it.publish()
+	it.notify()
 
 	it.mu.RLock()
 	for address, stat := range it.stats {
 		if stat.cpu < 0 {
-			log.Printf("address %s: initializing...", address)
+			logger.Debug("node initializing", "address", address)
+			continue
 		}
+		logger.Debug("node load", "address", address, "cpu", stat.cpu)
 	}
 	it.mu.RUnlock()
 }
@@ -160,11 +318,21 @@ func (it *Monitor) updateCPUStats() error {
 		wg         sync.WaitGroup
 	)
 
-	for _, nd := range it.nodes {
+	it.mu.RLock()
+	nodes := it.nodes
+	it.mu.RUnlock()
+	observer, logger := it.observer(), it.logger()
+
+	for _, nd := range nodes {
 		wg.Add(1)
 		go func(n node) {
 			defer wg.Done()
-			if err := it.updateNodeCPU(n); err != nil {
+			start := time.Now()
+			err := it.updateNodeCPU(n)
+			observer.Poll(n.address, time.Since(start), err)
+			if err != nil {
+				logger.Debug("failed to poll node", "address", n.address, "error", err)
+				it.setError(n.address, err)
 				mu.Lock()
 				errs = append(errs, fmt.Errorf("node %s: %w", n.address, err))
 				mu.Unlock()
@@ -193,7 +361,9 @@ func (it *Monitor) updateNodeCPU(n node) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
 
+	start := time.Now()
 	resp := n.client.Do(ctx, n.client.B().Info().Build())
+	latency := time.Since(start)
 	if err := resp.Error(); err != nil {
 		return fmt.Errorf("INFO command failed: %w", err)
 	}
@@ -203,10 +373,11 @@ func (it *Monitor) updateNodeCPU(n node) error {
 		return fmt.Errorf("failed to convert response to string: %w", err)
 	}
 
-	cpu, err := cpu.New(infoStr)
+	stats, err := stats.New(infoStr)
 	if err != nil {
 		return fmt.Errorf("parse error: %w", err)
 	}
+	cpu := stats.CPU
 
 	now := time.Now()
 
@@ -220,10 +391,16 @@ func (it *Monitor) updateNodeCPU(n node) error {
 
 	if cpu.User < prev.user || cpu.Sys < prev.sys {
 		it.stats[n.address] = info{
-			user:   cpu.User,
-			sys:    cpu.Sys,
-			cpu:    -1,
-			lastTs: now,
+			user:            cpu.User,
+			sys:             cpu.Sys,
+			rejected:        stats.RejectedConnections,
+			runID:           stats.RunID,
+			replication:     newReplication(stats.Replication),
+			raw:             -1,
+			cpu:             -1,
+			lastTs:          now,
+			smoother:        prev.smoother,
+			commandFailures: it.config.Failures.recovered(prev.commandFailures),
 		}
 		return nil
 	}
@@ -239,18 +416,36 @@ func (it *Monitor) updateNodeCPU(n node) error {
 
 	usagePercent := (totalDelta / deltaTime) * 100
 
+	rejected := stats.RejectedConnections - prev.rejected
+	if rejected < 0 {
+		rejected = 0
+	}
+
+	score := it.config.Load.score(signals{
+		cpu:      usagePercent,
+		stats:    stats,
+		rejected: rejected,
+		latency:  latency,
+	})
+
 	it.stats[n.address] = info{
-		user:   cpu.User,
-		sys:    cpu.Sys,
-		cpu:    usagePercent,
-		lastTs: now,
+		user:            cpu.User,
+		sys:             cpu.Sys,
+		rejected:        stats.RejectedConnections,
+		runID:           stats.RunID,
+		replication:     newReplication(stats.Replication),
+		raw:             score,
+		cpu:             prev.smoother.add(score, now),
+		lastTs:          now,
+		smoother:        prev.smoother,
+		commandFailures: it.config.Failures.recovered(prev.commandFailures),
 	}
 
 	return nil
 }
 
-// Snapshot возвращает копию текущей CPU-статистики.
-// Значения < 0 (например, -1) исключаются.
+// Snapshot возвращает копию текущей сглаженной оценки загрузки нод (по
+// умолчанию — загрузки CPU, %). Значения < 0 (например, -1) исключаются.
 func (it *Monitor) Snapshot() map[string]float64 {
 	it.mu.RLock()
 	defer it.mu.RUnlock()
@@ -264,6 +459,21 @@ func (it *Monitor) Snapshot() map[string]float64 {
 	return result
 }
 
+// Raw возвращает копию мгновенной оценки загрузки за последний период опроса
+// без сглаживания. Значения < 0 (например, -1) исключаются.
+func (it *Monitor) Raw() map[string]float64 {
+	it.mu.RLock()
+	defer it.mu.RUnlock()
+
+	result := make(map[string]float64, len(it.stats))
+	for addr, stat := range it.stats {
+		if stat.raw >= 0 {
+			result[addr] = stat.raw
+		}
+	}
+	return result
+}
+
 // WaitReady блокирует выполнение до тех пор, пока все узлы не будут инициализированы,
 // либо пока не будет превышено максимальное количество попыток.
 func (it *Monitor) WaitReady(timeout time.Duration, maxRetries int) error {
@@ -279,14 +489,34 @@ func (it *Monitor) WaitReady(timeout time.Duration, maxRetries int) error {
 		case <-ctx.Done():
 			return fmt.Errorf("monitor readiness timeout after %v", timeout)
 		case <-ticker.C:
-			if len(it.Snapshot()) == len(it.nodes) {
+			ready, total := len(it.Snapshot()), it.size()
+			if ready == total {
 				return nil
 			}
 			attempts++
 			if attempts > maxRetries {
 				return fmt.Errorf("monitor failed to initialize after %d attempts", maxRetries)
 			}
-			log.Printf("monitor initializing... (%d/%d nodes ready)", len(it.Snapshot()), len(it.nodes))
+			it.logger().Info("monitor initializing", "ready", ready, "total", total, "attempt", attempts)
 		}
 	}
 }
+
+// size возвращает число отслеживаемых нод.
+func (it *Monitor) size() int {
+	it.mu.RLock()
+	defer it.mu.RUnlock()
+	return len(it.nodes)
+}
+
+// setError запоминает ошибку опроса ноды.
+func (it *Monitor) setError(address string, err error) {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	if stat, ok := it.stats[address]; ok {
+		stat.err = err
+		stat.infoFailures++
+		it.stats[address] = stat
+	}
+}
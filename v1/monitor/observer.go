@@ -0,0 +1,30 @@
+package monitor
+
+import (
+	"time"
+)
+
+type (
+	// Observer получает события опроса нод, например для метрик.
+	Observer interface {
+		// Poll вызывается после каждого опроса INFO ноды; err — ошибка
+		// опроса или nil.
+		Poll(address string, latency time.Duration, err error)
+	}
+
+	// nopObserver наблюдатель по умолчанию.
+	nopObserver struct{}
+)
+
+func (nopObserver) Poll(string, time.Duration, error) {}
+
+// observer возвращает текущего наблюдателя.
+func (it *Monitor) observer() Observer {
+	it.mu.RLock()
+	defer it.mu.RUnlock()
+
+	if it.config.Observer == nil {
+		return nopObserver{}
+	}
+	return it.config.Observer
+}
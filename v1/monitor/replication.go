@@ -0,0 +1,148 @@
+package monitor
+
+import (
+	"net"
+	"slices"
+	"time"
+
+	"github.com/kuroko-shirai/axolotl/v1/internal/stats"
+)
+
+const (
+	RoleMaster  = "master"
+	RoleReplica = "slave"
+)
+
+type (
+	// Replication состояние репликации ноды.
+	Replication struct {
+		Role   string // RoleMaster или RoleReplica.
+		Master string // Адрес мастера реплики (host:port), как его сообщает реплика.
+		// ID идентификатор истории репликации (master_replid). Реплика после
+		// синхронизации получает ID своего мастера, поэтому по нему мастер
+		// находится, даже если реплика знает его по другому адресу.
+		ID     string
+		LinkUp bool          // Соединение реплики с мастером установлено.
+		LastIO time.Duration // Время с последнего обмена реплики с мастером; -1, если неизвестно.
+		Offset int64         // Смещение репликации: master_repl_offset мастера или slave_repl_offset реплики.
+		// LagBytes отставание реплики от мастера в байтах; -1, если мастер
+		// не найден среди отслеживаемых нод. Для мастера всегда 0.
+		LagBytes int64
+		Replicas []string // Адреса подключённых реплик мастера.
+	}
+)
+
+// newReplication переводит разобранную секцию INFO replication в
+// публичное представление без учёта отставания.
+func newReplication(r stats.Replication) Replication {
+	if r.Role != RoleReplica {
+		return Replication{
+			Role:     r.Role,
+			ID:       r.ReplID,
+			LinkUp:   true,
+			Offset:   r.MasterReplOffset,
+			Replicas: r.Replicas,
+		}
+	}
+
+	lastIO := time.Duration(-1)
+	if r.MasterLastIO >= 0 {
+		lastIO = time.Duration(r.MasterLastIO) * time.Second
+	}
+
+	var master string
+	if r.MasterHost != "" {
+		master = net.JoinHostPort(r.MasterHost, r.MasterPort)
+	}
+
+	return Replication{
+		Role:     RoleReplica,
+		Master:   master,
+		ID:       r.ReplID,
+		LinkUp:   r.MasterLinkUp,
+		LastIO:   lastIO,
+		Offset:   r.SlaveReplOffset,
+		LagBytes: -1,
+	}
+}
+
+// Replication возвращает состояние репликации всех нод по последнему опросу.
+// Отставание реплики в байтах считается относительно смещения её мастера,
+// если он тоже под мониторингом: по адресу или по идентификатору репликации.
+func (it *Monitor) Replication() map[string]Replication {
+	it.mu.RLock()
+	defer it.mu.RUnlock()
+
+	result := make(map[string]Replication, len(it.stats))
+	for addr, stat := range it.stats {
+		result[addr] = canonical(stat.replication, it.aliases)
+	}
+	withLag(result)
+
+	return result
+}
+
+// withLag заполняет LagBytes реплик, мастер которых отслеживается. Реплика
+// сообщает адрес мастера так, как видит его сама (обычно IP), поэтому, если
+// адрес не совпал, мастер ищется по идентификатору репликации.
+func withLag(result map[string]Replication) {
+	var byID map[string]int64
+	for addr, r := range result {
+		if r.Role != RoleReplica {
+			continue
+		}
+
+		offset, ok := int64(0), false
+		if master, found := result[r.Master]; found && master.Role == RoleMaster {
+			offset, ok = master.Offset, true
+		} else if r.ID != "" {
+			if byID == nil {
+				byID = mastersByID(result)
+			}
+			offset, ok = byID[r.ID]
+		}
+		if !ok {
+			continue
+		}
+
+		r.LagBytes = max(offset-r.Offset, 0)
+		result[addr] = r
+	}
+}
+
+// mastersByID возвращает смещения мастеров по идентификаторам репликации.
+func mastersByID(result map[string]Replication) map[string]int64 {
+	byID := make(map[string]int64)
+	for _, r := range result {
+		if r.Role == RoleMaster && r.ID != "" {
+			byID[r.ID] = r.Offset
+		}
+	}
+	return byID
+}
+
+// canonical заменяет в состоянии репликации другие адреса отслеживаемых нод
+// (обычно IP, которые сообщают ноды) на адреса, под которыми ноды
+// отслеживаются.
+func canonical(r Replication, aliases map[string]string) Replication {
+	if len(aliases) == 0 {
+		return r
+	}
+	if address, ok := aliases[r.Master]; ok {
+		r.Master = address
+	}
+	cloned := false
+	for i, replica := range r.Replicas {
+		address, ok := aliases[replica]
+		if !ok {
+			continue
+		}
+		if !cloned {
+			// Срез общий с сохранённым состоянием ноды.
+			r.Replicas = slices.Clone(r.Replicas)
+			cloned = true
+		}
+		r.Replicas[i] = address
+	}
+	return r
+}
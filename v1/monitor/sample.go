@@ -0,0 +1,39 @@
+package monitor
+
+import (
+	"time"
+)
+
+type (
+	// Sample последнее измерение загрузки ноды.
+	Sample struct {
+		Value  float64       // Сглаженная оценка загрузки; < 0, пока нода не инициализирована.
+		Raw    float64       // Мгновенная оценка загрузки; < 0, пока нода не инициализирована.
+		Time   time.Time     // Время последнего успешного опроса.
+		Age    time.Duration // Возраст измерения на момент вызова Samples.
+		Err    error         // Ошибка последнего опроса, если он не удался.
+		Health Health
+	}
+)
+
+// Samples возвращает последние измерения всех нод вместе со временем,
+// возрастом и ошибкой опроса. В отличие от Snapshot, ноды без значения и
+// ноды, переставшие отвечать, не отбрасываются.
+func (it *Monitor) Samples() map[string]Sample {
+	it.mu.RLock()
+	defer it.mu.RUnlock()
+
+	now := time.Now()
+	result := make(map[string]Sample, len(it.stats))
+	for addr, stat := range it.stats {
+		result[addr] = Sample{
+			Value:  stat.cpu,
+			Raw:    stat.raw,
+			Time:   stat.lastTs,
+			Age:    now.Sub(stat.lastTs),
+			Err:    stat.err,
+			Health: it.config.Failures.health(max(stat.infoFailures, stat.commandFailures)),
+		}
+	}
+	return result
+}
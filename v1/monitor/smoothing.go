@@ -0,0 +1,146 @@
+package monitor
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	SmoothingNone       SmoothingMode = iota // Последнее мгновенное значение.
+	SmoothingEWMA                            // Экспоненциальное сглаживание с периодом полураспада.
+	SmoothingWindow                          // Среднее по скользящему окну.
+	SmoothingPercentile                      // Перцентиль по скользящему окну.
+)
+
+type (
+	// SmoothingMode способ сглаживания загрузки CPU.
+	SmoothingMode uint8
+
+	// Smoothing содержит настройки сглаживания загрузки CPU.
+	Smoothing struct {
+		Mode       SmoothingMode
+		HalfLife   time.Duration // Период полураспада для SmoothingEWMA.
+		Window     int           // Размер окна для SmoothingWindow и SmoothingPercentile.
+		Percentile float64       // Перцентиль (0, 100] для SmoothingPercentile.
+	}
+
+	// smoother накапливает мгновенные значения и возвращает сглаженное.
+	smoother interface {
+		add(value float64, ts time.Time) float64
+	}
+
+	last struct{}
+
+	ewma struct {
+		halfLife time.Duration
+		value    float64
+		lastTs   time.Time
+		started  bool
+	}
+
+	window struct {
+		values     []float64
+		pos        int
+		full       bool
+		percentile float64 // 0 — среднее по окну.
+	}
+)
+
+// validate проверяет согласованность настроек сглаживания.
+func (it Smoothing) validate() error {
+	switch it.Mode {
+	case SmoothingNone:
+		return nil
+	case SmoothingEWMA:
+		if it.HalfLife <= 0 {
+			return errors.New("invalid EWMA smoothing: half-life must be positive")
+		}
+	case SmoothingWindow:
+		if it.Window <= 0 {
+			return errors.New("invalid window smoothing: window must be positive")
+		}
+	case SmoothingPercentile:
+		if it.Window <= 0 {
+			return errors.New("invalid percentile smoothing: window must be positive")
+		}
+		if it.Percentile <= 0 || it.Percentile > 100 {
+			return errors.New("invalid percentile smoothing: percentile must be in (0, 100]")
+		}
+	default:
+		return errors.New("unknown smoothing mode")
+	}
+	return nil
+}
+
+// newSmoother создаёт сглаживатель для одной ноды.
+func (it Smoothing) newSmoother() smoother {
+	switch it.Mode {
+	case SmoothingEWMA:
+		return &ewma{halfLife: it.HalfLife}
+	case SmoothingWindow:
+		return &window{values: make([]float64, it.Window)}
+	case SmoothingPercentile:
+		return &window{values: make([]float64, it.Window), percentile: it.Percentile}
+	default:
+		return last{}
+	}
+}
+
+func (it last) add(value float64, _ time.Time) float64 {
+	return value
+}
+
+func (it *ewma) add(value float64, ts time.Time) float64 {
+	if !it.started {
+		it.value, it.lastTs, it.started = value, ts, true
+		return it.value
+	}
+
+	// Вес нового значения зависит от прошедшего времени, поэтому
+	// нерегулярные опросы сглаживаются корректно.
+	elapsed := ts.Sub(it.lastTs)
+	alpha := 1 - math.Exp2(-float64(elapsed)/float64(it.halfLife))
+	it.value += alpha * (value - it.value)
+	it.lastTs = ts
+
+	return it.value
+}
+
+func (it *window) add(value float64, _ time.Time) float64 {
+	it.values[it.pos] = value
+	it.pos++
+	if it.pos == len(it.values) {
+		it.pos, it.full = 0, true
+	}
+
+	values := it.values[:it.pos]
+	if it.full {
+		values = it.values
+	}
+
+	if it.percentile == 0 {
+		sum := 0.0
+		for _, v := range values {
+			sum += v
+		}
+		return sum / float64(len(values))
+	}
+
+	return percentile(values, it.percentile)
+}
+
+// percentile возвращает перцентиль p (0, 100] методом ближайшего ранга.
+func percentile(values []float64, p float64) float64 {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+
+	return sorted[rank-1]
+}
@@ -0,0 +1,140 @@
+package monitor
+
+import (
+	"cmp"
+	"slices"
+	"time"
+)
+
+type (
+	// View неизменяемый снимок состояния нод с предрасчитанными
+	// агрегатами. Монитор публикует новый View после каждого опроса, после
+	// изменения состава нод и после смены состояния здоровья ноды, поэтому
+	// чтение View не копирует данные и не блокирует монитор. Поля View и
+	// вложенные карты и срезы нельзя изменять.
+	View struct {
+		Time        time.Time              // Момент публикации.
+		Loads       map[string]float64     // Загрузка нод с данными, как в Snapshot.
+		Oldest      time.Time              // Время самого старого измерения в Loads.
+		Times       map[string]time.Time   // Время измерения нод в Loads.
+		Health      map[string]Health      // Состояние здоровья всех нод, как в Health.
+		Replication map[string]Replication // Репликация всех нод, как в Replication.
+		Ordered     []string               // Адреса нод с данными по возрастанию загрузки.
+		Masters     RoleView               // Ноды с ролью мастера по данным INFO replication.
+		Replicas    RoleView               // Ноды с ролью реплики по данным INFO replication.
+	}
+
+	// RoleView агрегаты нод одной роли.
+	RoleView struct {
+		Addresses []string // Адреса нод с данными по возрастанию загрузки.
+		Median    float64  // Медиана загрузки; 0, если данных нет.
+	}
+)
+
+// View возвращает последний опубликованный снимок.
+func (it *Monitor) View() *View {
+	return it.view.Load()
+}
+
+// publish собирает и публикует новый снимок. Вызовы сериализуются, чтобы
+// более старый снимок не заменил более новый.
+func (it *Monitor) publish() {
+	it.publishMu.Lock()
+	defer it.publishMu.Unlock()
+
+	it.mu.RLock()
+	view := &View{
+		Time:        time.Now(),
+		Loads:       make(map[string]float64, len(it.stats)),
+		Times:       make(map[string]time.Time, len(it.stats)),
+		Health:      make(map[string]Health, len(it.stats)),
+		Replication: make(map[string]Replication, len(it.stats)),
+	}
+	for addr, stat := range it.stats {
+		view.Health[addr] = stat.health(it.config.Failures)
+		view.Replication[addr] = canonical(stat.replication, it.aliases)
+		if stat.cpu < 0 {
+			continue
+		}
+		view.Loads[addr] = stat.cpu
+		view.Times[addr] = stat.lastTs
+		if view.Oldest.IsZero() || stat.lastTs.Before(view.Oldest) {
+			view.Oldest = stat.lastTs
+		}
+	}
+	it.mu.RUnlock()
+
+	withLag(view.Replication)
+
+	view.Ordered = make([]string, 0, len(view.Loads))
+	for addr := range view.Loads {
+		view.Ordered = append(view.Ordered, addr)
+	}
+	slices.SortFunc(view.Ordered, func(a, b string) int {
+		return cmp.Or(cmp.Compare(view.Loads[a], view.Loads[b]), cmp.Compare(a, b))
+	})
+	view.withRoles()
+
+	it.view.Store(view)
+}
+
+// Since возвращает снимок без измерений старше since. Если таких измерений
+// нет, возвращается сам снимок. Здоровье и репликация нод остаются общими
+// с исходным снимком.
+func (it *View) Since(since time.Time) *View {
+	if !it.Oldest.Before(since) {
+		return it
+	}
+
+	view := &View{
+		Time:        it.Time,
+		Loads:       make(map[string]float64, len(it.Loads)),
+		Times:       make(map[string]time.Time, len(it.Times)),
+		Health:      it.Health,
+		Replication: it.Replication,
+		Ordered:     make([]string, 0, len(it.Ordered)),
+	}
+	for _, addr := range it.Ordered {
+		ts := it.Times[addr]
+		if ts.Before(since) {
+			continue
+		}
+		view.Loads[addr], view.Times[addr] = it.Loads[addr], ts
+		view.Ordered = append(view.Ordered, addr)
+		if view.Oldest.IsZero() || ts.Before(view.Oldest) {
+			view.Oldest = ts
+		}
+	}
+	view.withRoles()
+
+	return view
+}
+
+// withRoles раскладывает упорядоченные ноды снимка по ролям и считает
+// медианы ролей.
+func (it *View) withRoles() {
+	for _, addr := range it.Ordered {
+		switch it.Replication[addr].Role {
+		case RoleMaster:
+			it.Masters.Addresses = append(it.Masters.Addresses, addr)
+		case RoleReplica:
+			it.Replicas.Addresses = append(it.Replicas.Addresses, addr)
+		}
+	}
+	it.Masters.Median = sortedMedian(it.Masters.Addresses, it.Loads)
+	it.Replicas.Median = sortedMedian(it.Replicas.Addresses, it.Loads)
+}
+
+// sortedMedian возвращает медиану загрузки нод, упорядоченных по
+// возрастанию загрузки.
+func sortedMedian(addresses []string, loads map[string]float64) float64 {
+	n := len(addresses)
+	switch {
+	case n == 0:
+		return 0
+	case n%2 == 0:
+		return (loads[addresses[n/2-1]] + loads[addresses[n/2]]) * 0.5
+	default:
+		return loads[addresses[n/2]]
+	}
+}
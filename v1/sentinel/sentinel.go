@@ -0,0 +1,245 @@
+package sentinel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kuroko-shirai/axolotl/v1/cluster"
+	"github.com/redis/rueidis"
+)
+
+// events каналы sentinel, после сообщений в которых топология перечитывается.
+var events = []string{"+switch-master", "+sdown", "-sdown", "+odown", "-odown"}
+
+type (
+	// Config содержит поля настройки источника топологии на базе Redis
+	// Sentinel.
+	Config struct {
+		Addresses []string      // Адреса sentinel-нод.
+		MasterSet string        // Имя отслеживаемого мастера.
+		Username  string        // Пользователь sentinel.
+		Password  string        // Пароль sentinel.
+		Retry     time.Duration // Пауза перед переподключением подписки; по умолчанию 1s.
+		Logger    *slog.Logger  // Журнал; по умолчанию slog.Default().
+	}
+
+	// Sentinel источник топологии: мастер и реплики по данным sentinel.
+	// Реализует cobweb.Discovery.
+	Sentinel struct {
+		clients   []rueidis.Client
+		addresses []string
+		masterSet string
+		retry     time.Duration
+		logger    *slog.Logger
+		mu        sync.RWMutex
+		topology  cluster.Topology
+		watchers  []func(cluster.Topology)
+	}
+)
+
+func New(config Config) (*Sentinel, error) {
+	if len(config.Addresses) == 0 {
+		return nil, errors.New("invalid sentinel: need at least one sentinel address")
+	}
+
+	if config.MasterSet == "" {
+		return nil, errors.New("invalid sentinel: empty master set name")
+	}
+
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	clients := make([]rueidis.Client, 0, len(config.Addresses))
+	for _, address := range config.Addresses {
+		client, err := rueidis.NewClient(rueidis.ClientOption{
+			Username:          config.Username,
+			Password:          config.Password,
+			InitAddress:       []string{address},
+			ForceSingleClient: true,
+			DisableCache:      true,
+		})
+		if err != nil && client == nil {
+			for _, c := range clients {
+				c.Close()
+			}
+			return nil, fmt.Errorf("failed to create sentinel client for %s: %w", address, err)
+		}
+		if err != nil {
+			// Недоступный sentinel не мешает работе с остальными: клиент
+			// переподключится при следующем запросе.
+			logger.Warn("failed to connect to sentinel", "address", address, "error", err)
+		}
+		clients = append(clients, client)
+	}
+
+	retry := config.Retry
+	if retry == 0 {
+		retry = time.Second
+	}
+
+	topology, err := resolve(context.Background(), clients, config.MasterSet)
+	if err != nil {
+		for _, client := range clients {
+			client.Close()
+		}
+		return nil, err
+	}
+
+	return &Sentinel{
+		clients:   clients,
+		addresses: config.Addresses,
+		masterSet: config.MasterSet,
+		retry:     retry,
+		logger:    logger,
+		topology:  topology,
+	}, nil
+}
+
+func (it *Sentinel) Close() {
+	for _, client := range it.clients {
+		client.Close()
+	}
+}
+
+// Topology возвращает последнюю известную раскладку нод.
+func (it *Sentinel) Topology() cluster.Topology {
+	it.mu.RLock()
+	defer it.mu.RUnlock()
+	return it.topology
+}
+
+// Watch регистрирует обработчик изменений топологии.
+func (it *Sentinel) Watch(fn func(cluster.Topology)) {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	it.watchers = append(it.watchers, fn)
+}
+
+// Run подписывается на события sentinel и перечитывает топологию после
+// каждого из них, а также после переподключения подписки, чтобы не
+// пропустить события, случившиеся во время разрыва. При ошибке подписка
+// переходит на следующий sentinel.
+func (it *Sentinel) Run(ctx context.Context) {
+	for i := 0; ; i = (i + 1) % len(it.clients) {
+		client := it.clients[i]
+		subscribe := client.B().Subscribe().Channel(events...).Build()
+
+		it.refresh(ctx)
+		err := client.Receive(ctx, subscribe, func(msg rueidis.PubSubMessage) {
+			it.logger.Info("sentinel event", "address", it.addresses[i], "channel", msg.Channel, "message", msg.Message)
+			it.refresh(ctx)
+		})
+
+		select {
+		case <-ctx.Done():
+			it.logger.Info("sentinel stopped")
+			return
+		case <-time.After(it.retry):
+		}
+
+		if err != nil {
+			it.logger.Warn("sentinel subscription failed", "address", it.addresses[i], "error", err)
+		}
+	}
+}
+
+// refresh перечитывает топологию и оповещает обработчики, если она
+// изменилась.
+func (it *Sentinel) refresh(ctx context.Context) {
+	topology, err := resolve(ctx, it.clients, it.masterSet)
+	if err != nil {
+		it.logger.Warn("failed to resolve topology", "error", err)
+		return
+	}
+
+	it.mu.Lock()
+	if slices.Equal(topology.Masters, it.topology.Masters) && slices.Equal(topology.Replicas, it.topology.Replicas) {
+		it.mu.Unlock()
+		return
+	}
+	it.topology = topology
+	watchers := it.watchers
+	it.mu.Unlock()
+
+	for _, fn := range watchers {
+		fn(topology)
+	}
+}
+
+// resolve запрашивает мастер и исправные реплики у первого ответившего
+// sentinel.
+func resolve(ctx context.Context, clients []rueidis.Client, masterSet string) (cluster.Topology, error) {
+	var errs []error
+	for _, client := range clients {
+		topology, err := query(ctx, client, masterSet)
+		if err == nil {
+			return topology, nil
+		}
+		errs = append(errs, err)
+	}
+
+	return cluster.Topology{}, fmt.Errorf("no sentinel resolved %s: %w", masterSet, errors.Join(errs...))
+}
+
+// query запрашивает топологию у одного sentinel.
+func query(ctx context.Context, client rueidis.Client, masterSet string) (cluster.Topology, error) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	results := client.DoMulti(ctx,
+		client.B().SentinelGetMasterAddrByName().Master(masterSet).Build(),
+		client.B().SentinelReplicas().Master(masterSet).Build(),
+	)
+
+	master, err := results[0].AsStrSlice()
+	if err != nil {
+		return cluster.Topology{}, fmt.Errorf("failed to get master address: %w", err)
+	}
+	if len(master) != 2 {
+		return cluster.Topology{}, fmt.Errorf("unexpected master address: %v", master)
+	}
+
+	entries, err := results[1].ToArray()
+	if err != nil {
+		return cluster.Topology{}, fmt.Errorf("failed to get replicas: %w", err)
+	}
+
+	replicas := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		fields, err := entry.AsStrMap()
+		if err != nil {
+			return cluster.Topology{}, fmt.Errorf("failed to parse replica: %w", err)
+		}
+		if !healthy(fields["flags"]) {
+			continue
+		}
+		replicas = append(replicas, net.JoinHostPort(fields["ip"], fields["port"]))
+	}
+	slices.Sort(replicas)
+
+	return cluster.Topology{
+		Masters:  []string{net.JoinHostPort(master[0], master[1])},
+		Replicas: replicas,
+	}, nil
+}
+
+// healthy проверяет флаги реплики: недоступные и отключённые реплики в
+// раскладку не входят.
+func healthy(flags string) bool {
+	for _, flag := range strings.Split(flags, ",") {
+		switch flag {
+		case "s_down", "o_down", "disconnected":
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,107 @@
+// Package tracing адаптирует трассировку cobweb к OpenTelemetry.
+package tracing
+
+import (
+	"context"
+
+	"github.com/kuroko-shirai/axolotl/v1/cobweb"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const spanName = "cobweb.Execute"
+
+// Атрибуты span.
+const (
+	AttrExecutor       = attribute.Key("cobweb.executor")
+	AttrGroup          = attribute.Key("cobweb.group")
+	AttrReason         = attribute.Key("cobweb.reason")
+	AttrMastersMedian  = attribute.Key("cobweb.median.masters")
+	AttrReplicasMedian = attribute.Key("cobweb.median.replicas")
+	AttrAddress        = attribute.Key("server.address")
+	AttrSystem         = attribute.Key("db.system.name")
+	AttrOperation      = attribute.Key("db.operation.name")
+	AttrBatchSize      = attribute.Key("db.operation.batch.size")
+)
+
+// batch имя операции пакета команд.
+const batch = "BATCH"
+
+type (
+	// Tracer реализует cobweb.Tracer поверх трассировщика OpenTelemetry.
+	Tracer struct {
+		tracer trace.Tracer
+	}
+
+	// span реализует cobweb.Span.
+	span struct {
+		span trace.Span
+	}
+)
+
+func New(tracer trace.Tracer) *Tracer {
+	return &Tracer{
+		tracer: tracer,
+	}
+}
+
+// Start начинает span клиентского вызова Redis с медианами групп и
+// именами команд.
+func (it *Tracer) Start(ctx context.Context, call cobweb.Call) (context.Context, cobweb.Span) {
+	attributes := []attribute.KeyValue{
+		AttrSystem.String("redis"),
+		AttrExecutor.String(call.Executor),
+		AttrMastersMedian.Float64(call.Medians.Masters),
+		AttrReplicasMedian.Float64(call.Medians.Replicas),
+	}
+	if len(call.Commands) > 0 {
+		attributes = append(attributes, AttrOperation.String(operation(call.Commands)))
+	}
+	if len(call.Commands) > 1 {
+		attributes = append(attributes, AttrBatchSize.Int(len(call.Commands)))
+	}
+
+	ctx, s := it.tracer.Start(ctx, spanName,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attributes...),
+	)
+	return ctx, &span{span: s}
+}
+
+// Route записывает выбор ноды событием и атрибутами span: атрибуты
+// отражают последнюю выбранную ноду.
+func (it *span) Route(target cobweb.Target, reason cobweb.Reason) {
+	attributes := []attribute.KeyValue{
+		AttrGroup.String(target.Group.String()),
+		AttrAddress.String(target.Address),
+		AttrReason.String(string(reason)),
+	}
+
+	it.span.AddEvent("route", trace.WithAttributes(attributes...))
+	it.span.SetAttributes(attributes...)
+}
+
+// End завершает span, записывая ошибку.
+func (it *span) End(err error) {
+	if err != nil {
+		it.span.RecordError(err)
+		it.span.SetStatus(codes.Error, err.Error())
+	}
+	it.span.End()
+}
+
+// operation возвращает имя операции: имя команды, а для пакета — BATCH и
+// имя команды, если она в пакете одна и та же, иначе просто BATCH. Длина
+// имени не зависит от размера пакета.
+func operation(commands []string) string {
+	if len(commands) == 1 {
+		return commands[0]
+	}
+	for _, command := range commands[1:] {
+		if command != commands[0] {
+			return batch
+		}
+	}
+	return batch + " " + commands[0]
+}
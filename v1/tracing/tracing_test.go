@@ -0,0 +1,143 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/kuroko-shirai/axolotl/v1/cobweb"
+	"github.com/kuroko-shirai/axolotl/v1/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// record выполняет fn с трассировщиком поверх экспортёра в памяти и
+// возвращает единственный завершённый span.
+func record(t *testing.T, fn func(tracer *tracing.Tracer)) tracetest.SpanStub {
+	t.Helper()
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer provider.Shutdown(context.Background())
+
+	fn(tracing.New(provider.Tracer("test")))
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	return spans[0]
+}
+
+// attributes переводит набор атрибутов в карту.
+func attributes(kvs []attribute.KeyValue) map[attribute.Key]attribute.Value {
+	result := make(map[attribute.Key]attribute.Value, len(kvs))
+	for _, kv := range kvs {
+		result[kv.Key] = kv.Value
+	}
+	return result
+}
+
+func TestSpan(t *testing.T) {
+	failed := errors.New("LOADING Redis is loading the dataset in memory")
+
+	stub := record(t, func(tracer *tracing.Tracer) {
+		_, span := tracer.Start(context.Background(), cobweb.Call{
+			Executor: "SingleCmd",
+			Commands: []string{"GET"},
+			Medians:  cobweb.Medians{Masters: 40, Replicas: 80},
+		})
+		span.Route(cobweb.Target{Group: cobweb.GroupReplicas, Address: "10.0.0.2:6379"}, cobweb.ReasonPolicy)
+		span.Route(cobweb.Target{Group: cobweb.GroupMasters, Address: "10.0.0.1:6379"}, cobweb.ReasonRetry)
+		span.End(failed)
+	})
+
+	if stub.Name != "cobweb.Execute" {
+		t.Errorf("name = %q", stub.Name)
+	}
+	if stub.SpanKind != trace.SpanKindClient {
+		t.Errorf("kind = %v, want client", stub.SpanKind)
+	}
+
+	got := attributes(stub.Attributes)
+	for key, want := range map[attribute.Key]attribute.Value{
+		tracing.AttrSystem:         attribute.StringValue("redis"),
+		tracing.AttrExecutor:       attribute.StringValue("SingleCmd"),
+		tracing.AttrOperation:      attribute.StringValue("GET"),
+		tracing.AttrMastersMedian:  attribute.Float64Value(40),
+		tracing.AttrReplicasMedian: attribute.Float64Value(80),
+		// Атрибуты маршрута отражают последнюю выбранную ноду.
+		tracing.AttrGroup:   attribute.StringValue("masters"),
+		tracing.AttrAddress: attribute.StringValue("10.0.0.1:6379"),
+		tracing.AttrReason:  attribute.StringValue("retry"),
+	} {
+		if got[key] != want {
+			t.Errorf("attribute %s = %v, want %v", key, got[key].Emit(), want.Emit())
+		}
+	}
+	if _, ok := got[tracing.AttrBatchSize]; ok {
+		t.Errorf("unexpected %s on a single command", tracing.AttrBatchSize)
+	}
+
+	var routes []map[attribute.Key]attribute.Value
+	for _, event := range stub.Events {
+		if event.Name == "route" {
+			routes = append(routes, attributes(event.Attributes))
+		}
+	}
+	if len(routes) != 2 {
+		t.Fatalf("got %d route events, want 2", len(routes))
+	}
+	if routes[0][tracing.AttrAddress].AsString() != "10.0.0.2:6379" || routes[0][tracing.AttrReason].AsString() != "policy" {
+		t.Errorf("first route = %v", routes[0])
+	}
+	if routes[1][tracing.AttrAddress].AsString() != "10.0.0.1:6379" || routes[1][tracing.AttrReason].AsString() != "retry" {
+		t.Errorf("second route = %v", routes[1])
+	}
+
+	if stub.Status.Code != codes.Error || stub.Status.Description != failed.Error() {
+		t.Errorf("status = %+v, want error %q", stub.Status, failed)
+	}
+}
+
+func TestSpanOK(t *testing.T) {
+	stub := record(t, func(tracer *tracing.Tracer) {
+		_, span := tracer.Start(context.Background(), cobweb.Call{Executor: "CacheCmd", Commands: []string{"GET"}})
+		span.End(nil)
+	})
+
+	if stub.Status.Code != codes.Unset {
+		t.Errorf("status = %+v, want unset", stub.Status)
+	}
+}
+
+func TestBatchOperation(t *testing.T) {
+	tests := []struct {
+		name      string
+		commands  []string
+		operation string
+	}{
+		{name: "same command", commands: []string{"GET", "GET", "GET"}, operation: "BATCH GET"},
+		{name: "mixed commands", commands: []string{"GET", "HGETALL", "GET"}, operation: "BATCH"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := record(t, func(tracer *tracing.Tracer) {
+				_, span := tracer.Start(context.Background(), cobweb.Call{Executor: "MultiCmd", Commands: tt.commands})
+				span.End(nil)
+			})
+
+			got := attributes(stub.Attributes)
+			if op := got[tracing.AttrOperation].AsString(); op != tt.operation {
+				t.Errorf("operation = %q, want %q", op, tt.operation)
+			}
+			if size := got[tracing.AttrBatchSize].AsInt64(); size != int64(len(tt.commands)) {
+				t.Errorf("batch size = %d, want %d", size, len(tt.commands))
+			}
+		})
+	}
+}